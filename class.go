@@ -35,7 +35,7 @@ const (
 	ClassMOE Class = 0x0010 // MOE
 	// Function parameter passed in register.
 	ClassREGPARM Class = 0x0011 // REGPARM
-	// TODO: Figure out when FIELD is used.
+	// Bitfield member of structure or union; offset and size in bits.
 	ClassFIELD Class = 0x0012 // FIELD
 	// End of symbol.
 	ClassEOS Class = 0x0066 // EOS
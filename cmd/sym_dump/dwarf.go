@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mefistotelis/psx_mnd_sym/csym"
+	"github.com/mefistotelis/psx_mnd_sym/csym/c"
+)
+
+// DWARF version 2 attribute forms.
+const (
+	formAddr   = 0x01
+	formData2  = 0x05
+	formData4  = 0x06
+	formString = 0x08
+	formBlock1 = 0x0A
+	formData1  = 0x0B
+	formFlag   = 0x0C
+	formRef4   = 0x13
+)
+
+// DWARF constants of base type encodings, location operations and languages.
+const (
+	ateFloat        = 0x04
+	ateSigned       = 0x05
+	ateSignedChar   = 0x06
+	ateUnsigned     = 0x07
+	ateUnsignedChar = 0x08
+
+	opAddr       = 0x03
+	opPlusUconst = 0x23
+	opReg0       = 0x50
+	opBreg0      = 0x70
+	opFbreg      = 0x91
+
+	langC89 = 0x0001
+)
+
+// DWARF line number program opcodes.
+const (
+	lnsCopy        = 0x01
+	lnsAdvanceLine = 0x03
+	lnsSetFile     = 0x04
+	lneEndSequence = 0x01
+	lneSetAddress  = 0x02
+	// First special opcode; no special opcodes are used.
+	lineOpcodeBase = 10
+)
+
+// A die is a debugging information entry being built.
+type die struct {
+	// Tag of the entry.
+	tag dwarf.Tag
+	// Attributes of the entry.
+	attrs []dieAttr
+	// Child entries.
+	children []*die
+	// Offset of the entry in the compilation unit; set when writing.
+	offset uint32
+	// Abbreviation code; set when writing.
+	code uint64
+}
+
+// A dieAttr is an attribute of a debugging information entry. The value is
+// uint32, string, []byte, bool or a *die reference, depending on the form.
+type dieAttr struct {
+	// Attribute.
+	attr dwarf.Attr
+	// Attribute form.
+	form uint8
+	// Attribute value.
+	val interface{}
+}
+
+// add adds the given attribute to the entry.
+func (d *die) add(attr dwarf.Attr, form uint8, val interface{}) {
+	d.attrs = append(d.attrs, dieAttr{attr: attr, form: form, val: val})
+}
+
+// addChild adds a child entry with the given tag to the entry, and returns it.
+func (d *die) addChild(tag dwarf.Tag) *die {
+	child := &die{tag: tag}
+	d.children = append(d.children, child)
+	return child
+}
+
+// A dwarfBuilder tracks the state of building the debug information of an
+// overlay.
+type dwarfBuilder struct {
+	// Compilation unit entry.
+	cu *die
+	// types maps from types to their entries.
+	types map[c.Type]*die
+	// typedefs maps from typedef definitions to their entries.
+	typedefs map[*c.VarDecl]*die
+	// files maps from source file paths to their index in the line number
+	// program file table (starting at 1).
+	files map[string]int
+	// Source file paths in order of index.
+	paths []string
+}
+
+// buildDWARF returns the contents of the .debug_abbrev, .debug_info and
+// .debug_line sections describing the types of the parser and the declarations
+// and line numbers of the overlay, with a single compilation unit covering the
+// given address range.
+func buildDWARF(types *csym.Parser, overlay *csym.Overlay, start, end uint32) (abbrev, info, line []byte) {
+	b := &dwarfBuilder{
+		cu:       &die{tag: dwarf.TagCompileUnit},
+		types:    make(map[c.Type]*die),
+		typedefs: make(map[*c.VarDecl]*die),
+		files:    make(map[string]int),
+	}
+	name := "sym"
+	for _, f := range overlay.Funcs {
+		if len(f.Path) > 0 {
+			name = f.Path
+			break
+		}
+	}
+	b.cu.add(dwarf.AttrName, formString, name)
+	b.cu.add(dwarf.AttrProducer, formString, "sym_dump")
+	b.cu.add(dwarf.AttrLanguage, formData2, uint32(langC89))
+	b.cu.add(dwarf.AttrLowpc, formAddr, start)
+	b.cu.add(dwarf.AttrHighpc, formAddr, end)
+	b.cu.add(dwarf.AttrStmtList, formData4, uint32(0))
+	// Types.
+	if def, ok := types.Types["bool"].(*c.VarDecl); ok {
+		b.typedefDIE(def)
+	}
+	for _, t := range types.OrderTypes().Defs {
+		if def, ok := t.(*c.VarDecl); ok {
+			b.typedefDIE(def)
+			continue
+		}
+		b.typeDIE(t)
+	}
+	// Declarations.
+	for _, f := range overlay.Funcs {
+		b.funcDIE(f)
+	}
+	for _, v := range overlay.Vars {
+		d := b.cu.addChild(dwarf.TagVariable)
+		d.add(dwarf.AttrName, formString, v.Name)
+		b.addType(d, v.Type)
+		d.add(dwarf.AttrExternal, formFlag, v.Class != c.Static)
+		d.add(dwarf.AttrLocation, formBlock1, addrLoc(v.Addr))
+	}
+	line = b.lineProgram(overlay.Lines)
+	abbrev, info = writeDIEs(b.cu)
+	return abbrev, info, line
+}
+
+// typedefDIE returns the entry of the given typedef definition.
+func (b *dwarfBuilder) typedefDIE(def *c.VarDecl) *die {
+	if d, ok := b.typedefs[def]; ok {
+		return d
+	}
+	d := b.cu.addChild(dwarf.TagTypedef)
+	b.typedefs[def] = d
+	d.add(dwarf.AttrName, formString, def.Name)
+	b.addType(d, def.Type)
+	return d
+}
+
+// addType adds a type attribute referring to the entry of the given type to d.
+// Void types are denoted by the absence of the attribute.
+func (b *dwarfBuilder) addType(d *die, t c.Type) {
+	if t == c.Void {
+		return
+	}
+	d.add(dwarf.AttrType, formRef4, b.typeDIE(t))
+}
+
+// typeDIE returns the entry of the given type.
+func (b *dwarfBuilder) typeDIE(t c.Type) *die {
+	if typedef, ok := t.(*c.TypedefType); ok {
+		return b.typedefDIE(typedef.Typedef)
+	}
+	if d, ok := b.types[t]; ok {
+		return d
+	}
+	switch t := t.(type) {
+	case c.BaseType:
+		d := b.cu.addChild(dwarf.TagBaseType)
+		b.types[t] = d
+		d.add(dwarf.AttrName, formString, t.String())
+		d.add(dwarf.AttrEncoding, formData1, uint32(baseEncoding(t)))
+		d.add(dwarf.AttrByteSize, formData1, c.Sizeof(t))
+		return d
+	case *c.StructType:
+		d := b.cu.addChild(dwarf.TagStructType)
+		b.types[t] = d
+		d.add(dwarf.AttrName, formString, t.Tag)
+		d.add(dwarf.AttrByteSize, formData4, c.Sizeof(t))
+		b.addFields(d, t.Fields)
+		return d
+	case *c.UnionType:
+		d := b.cu.addChild(dwarf.TagUnionType)
+		b.types[t] = d
+		d.add(dwarf.AttrName, formString, t.Tag)
+		d.add(dwarf.AttrByteSize, formData4, c.Sizeof(t))
+		b.addFields(d, t.Fields)
+		return d
+	case *c.EnumType:
+		d := b.cu.addChild(dwarf.TagEnumerationType)
+		b.types[t] = d
+		d.add(dwarf.AttrName, formString, t.Tag)
+		d.add(dwarf.AttrByteSize, formData1, uint32(4))
+		for _, member := range t.Members {
+			m := d.addChild(dwarf.TagEnumerator)
+			m.add(dwarf.AttrName, formString, member.Name)
+			m.add(dwarf.AttrConstValue, formData4, member.Value)
+		}
+		return d
+	case *c.PointerType:
+		d := b.cu.addChild(dwarf.TagPointerType)
+		b.types[t] = d
+		d.add(dwarf.AttrByteSize, formData1, uint32(4))
+		b.addType(d, t.Elem)
+		return d
+	case *c.ArrayType:
+		d := b.cu.addChild(dwarf.TagArrayType)
+		b.types[t] = d
+		b.addType(d, t.Elem)
+		sub := d.addChild(dwarf.TagSubrangeType)
+		if t.Len > 0 {
+			sub.add(dwarf.AttrUpperBound, formData4, uint32(t.Len-1))
+		}
+		return d
+	case *c.FuncType:
+		d := b.cu.addChild(dwarf.TagSubroutineType)
+		b.types[t] = d
+		b.addType(d, t.RetType)
+		d.add(dwarf.AttrPrototyped, formFlag, true)
+		for _, param := range t.Params {
+			p := d.addChild(dwarf.TagFormalParameter)
+			b.addType(p, param.Type)
+		}
+		if t.Variadic {
+			d.addChild(dwarf.TagUnspecifiedParameters)
+		}
+		return d
+	}
+	panic(fmt.Errorf("support for type %T not yet implemented", t))
+}
+
+// addFields adds member entries of the given struct or union fields to d.
+func (b *dwarfBuilder) addFields(d *die, fields []c.Field) {
+	for _, field := range fields {
+		m := d.addChild(dwarf.TagMember)
+		if len(field.Name) > 0 {
+			m.add(dwarf.AttrName, formString, field.Name)
+		}
+		b.addType(m, field.Type)
+		offset := field.Offset
+		if field.BitWidth > 0 {
+			// DWARF 2 specifies bitfields by the offset of their storage unit,
+			// and the bit offset from the most significant bit of the unit.
+			unit := c.Sizeof(field.Type)
+			if unit == 0 {
+				unit = 4
+			}
+			offset = field.BitOffset / 8 / unit * unit
+			bit := field.BitOffset - offset*8
+			m.add(dwarf.AttrByteSize, formData1, unit)
+			m.add(dwarf.AttrBitSize, formData1, field.BitWidth)
+			m.add(dwarf.AttrBitOffset, formData1, unit*8-bit-field.BitWidth)
+		}
+		loc := []byte{opPlusUconst}
+		loc = appendUleb(loc, uint64(offset))
+		m.add(dwarf.AttrDataMemberLoc, formBlock1, loc)
+	}
+}
+
+// funcDIE adds the entry of the given function declaration, with its parameters
+// and local variables.
+func (b *dwarfBuilder) funcDIE(f *c.FuncDecl) {
+	d := b.cu.addChild(dwarf.TagSubprogram)
+	d.add(dwarf.AttrName, formString, f.Name)
+	funcType, ok := f.Type.(*c.FuncType)
+	if !ok {
+		panic(fmt.Errorf("invalid function type %T of %q", f.Type, f.Name))
+	}
+	b.addType(d, funcType.RetType)
+	d.add(dwarf.AttrPrototyped, formFlag, true)
+	d.add(dwarf.AttrExternal, formFlag, true)
+	d.add(dwarf.AttrLowpc, formAddr, f.Addr)
+	d.add(dwarf.AttrHighpc, formAddr, f.Addr+funcSize(f))
+	if len(f.Path) > 0 {
+		d.add(dwarf.AttrDeclFile, formData4, uint32(b.fileIndex(f.Path)))
+		d.add(dwarf.AttrDeclLine, formData4, f.LineStart)
+	}
+	// Stack variables are relative to the frame pointer register.
+	fp := uint32(c.RegSP)
+	if f.Frame != nil {
+		fp = f.Frame.FP
+	}
+	d.add(dwarf.AttrFrameBase, formBlock1, []byte{byte(opBreg0 + fp), 0})
+	for _, param := range funcType.Params {
+		p := d.addChild(dwarf.TagFormalParameter)
+		b.addVar(p, param)
+	}
+	if funcType.Variadic {
+		d.addChild(dwarf.TagUnspecifiedParameters)
+	}
+	for _, block := range f.Blocks {
+		for _, local := range block.Locals {
+			if local.Class == c.Typedef {
+				continue
+			}
+			v := d.addChild(dwarf.TagVariable)
+			b.addVar(v, local)
+		}
+	}
+}
+
+// addVar adds the name, type and location attributes of the given parameter or
+// local variable to d.
+func (b *dwarfBuilder) addVar(d *die, v *c.VarDecl) {
+	if len(v.Name) > 0 {
+		d.add(dwarf.AttrName, formString, v.Name)
+	}
+	b.addType(d, v.Type)
+	switch v.Class {
+	case c.Register:
+		if v.Addr < 32 {
+			d.add(dwarf.AttrLocation, formBlock1, []byte{byte(opReg0 + v.Addr)})
+		}
+	case c.Static:
+		d.add(dwarf.AttrLocation, formBlock1, addrLoc(v.Addr))
+	case c.Extern:
+		// Declared elsewhere.
+	default:
+		// Stack locations relative to the frame base.
+		loc := []byte{opFbreg}
+		loc = appendSleb(loc, int64(int32(v.Addr)))
+		d.add(dwarf.AttrLocation, formBlock1, loc)
+	}
+}
+
+// fileIndex returns the index of the given source file in the file table of the
+// line number program.
+func (b *dwarfBuilder) fileIndex(path string) int {
+	if index, ok := b.files[path]; ok {
+		return index
+	}
+	b.paths = append(b.paths, path)
+	index := len(b.paths)
+	b.files[path] = index
+	return index
+}
+
+// lineProgram returns the contents of the .debug_line section, holding a line
+// number program of the given lines.
+func (b *dwarfBuilder) lineProgram(lines []*csym.Line) []byte {
+	lines = append([]*csym.Line(nil), lines...)
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Addr < lines[j].Addr
+	})
+	prog := &bytes.Buffer{}
+	file, row := 1, 1
+	setAddress := func(addr uint32) {
+		prog.Write([]byte{0, 5, lneSetAddress})
+		binary.Write(prog, binary.LittleEndian, addr)
+	}
+	for _, l := range lines {
+		if index := b.fileIndex(l.Path); index != file {
+			prog.WriteByte(lnsSetFile)
+			prog.Write(appendUleb(nil, uint64(index)))
+			file = index
+		}
+		setAddress(l.Addr)
+		if delta := int64(l.Line) - int64(row); delta != 0 {
+			prog.WriteByte(lnsAdvanceLine)
+			prog.Write(appendSleb(nil, delta))
+			row = int(l.Line)
+		}
+		prog.WriteByte(lnsCopy)
+	}
+	if len(lines) > 0 {
+		setAddress(lines[len(lines)-1].Addr + 4)
+		prog.Write([]byte{0, 1, lneEndSequence})
+	}
+	// Header following the header length field.
+	hdr := &bytes.Buffer{}
+	hdr.WriteByte(4)                             // minimum instruction length
+	hdr.WriteByte(1)                             // default is_stmt
+	hdr.WriteByte(0xFB)                          // line base (-5)
+	hdr.WriteByte(14)                            // line range
+	hdr.WriteByte(lineOpcodeBase)                // opcode base
+	hdr.Write([]byte{0, 1, 1, 1, 1, 0, 0, 0, 1}) // standard opcode lengths
+	hdr.WriteByte(0)                             // no include directories
+	for _, path := range b.paths {
+		hdr.WriteString(path)
+		hdr.Write([]byte{0, 0, 0, 0}) // directory, modification time, length
+	}
+	hdr.WriteByte(0)
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, uint32(2+4+hdr.Len()+prog.Len()))
+	binary.Write(buf, binary.LittleEndian, uint16(2))
+	binary.Write(buf, binary.LittleEndian, uint32(hdr.Len()))
+	buf.Write(hdr.Bytes())
+	buf.Write(prog.Bytes())
+	return buf.Bytes()
+}
+
+// writeDIEs returns the contents of the .debug_abbrev and .debug_info sections
+// holding the given compilation unit.
+func writeDIEs(cu *die) (abbrev, info []byte) {
+	// Assign abbreviation codes and offsets.
+	abbrevBuf := &bytes.Buffer{}
+	codes := make(map[string]uint64)
+	const cuHeaderSize = 11
+	offset := uint32(cuHeaderSize)
+	var layout func(d *die)
+	layout = func(d *die) {
+		key := &strings.Builder{}
+		fmt.Fprintf(key, "%d %v", d.tag, len(d.children) > 0)
+		for _, a := range d.attrs {
+			fmt.Fprintf(key, " %d:%d", a.attr, a.form)
+		}
+		code, ok := codes[key.String()]
+		if !ok {
+			code = uint64(len(codes) + 1)
+			codes[key.String()] = code
+			abbrevBuf.Write(appendUleb(nil, code))
+			abbrevBuf.Write(appendUleb(nil, uint64(d.tag)))
+			if len(d.children) > 0 {
+				abbrevBuf.WriteByte(1)
+			} else {
+				abbrevBuf.WriteByte(0)
+			}
+			for _, a := range d.attrs {
+				abbrevBuf.Write(appendUleb(nil, uint64(a.attr)))
+				abbrevBuf.Write(appendUleb(nil, uint64(a.form)))
+			}
+			abbrevBuf.Write([]byte{0, 0})
+		}
+		d.code = code
+		d.offset = offset
+		offset += uint32(len(appendUleb(nil, code)))
+		for _, a := range d.attrs {
+			offset += uint32(attrSize(a))
+		}
+		if len(d.children) > 0 {
+			for _, child := range d.children {
+				layout(child)
+			}
+			offset++ // null entry terminating the children.
+		}
+	}
+	layout(cu)
+	abbrevBuf.WriteByte(0)
+	// Write entries.
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, offset-4)  // unit length
+	binary.Write(buf, binary.LittleEndian, uint16(2)) // version
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // abbreviation offset
+	buf.WriteByte(4)                                  // address size
+	var write func(d *die)
+	write = func(d *die) {
+		buf.Write(appendUleb(nil, d.code))
+		for _, a := range d.attrs {
+			writeAttr(buf, a)
+		}
+		if len(d.children) > 0 {
+			for _, child := range d.children {
+				write(child)
+			}
+			buf.WriteByte(0)
+		}
+	}
+	write(cu)
+	return abbrevBuf.Bytes(), buf.Bytes()
+}
+
+// attrSize returns the size in bytes of the given attribute value.
+func attrSize(a dieAttr) int {
+	switch a.form {
+	case formAddr, formData4, formRef4:
+		return 4
+	case formData2:
+		return 2
+	case formData1, formFlag:
+		return 1
+	case formString:
+		return len(a.val.(string)) + 1
+	case formBlock1:
+		return 1 + len(a.val.([]byte))
+	}
+	panic(fmt.Errorf("support for attribute form 0x%02X not yet implemented", a.form))
+}
+
+// writeAttr writes the given attribute value to buf.
+func writeAttr(buf *bytes.Buffer, a dieAttr) {
+	switch a.form {
+	case formAddr, formData4:
+		binary.Write(buf, binary.LittleEndian, a.val.(uint32))
+	case formRef4:
+		binary.Write(buf, binary.LittleEndian, a.val.(*die).offset)
+	case formData2:
+		binary.Write(buf, binary.LittleEndian, uint16(a.val.(uint32)))
+	case formData1:
+		buf.WriteByte(uint8(a.val.(uint32)))
+	case formFlag:
+		if a.val.(bool) {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case formString:
+		buf.WriteString(a.val.(string))
+		buf.WriteByte(0)
+	case formBlock1:
+		block := a.val.([]byte)
+		buf.WriteByte(uint8(len(block)))
+		buf.Write(block)
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// baseEncoding returns the DWARF encoding of the given base type.
+func baseEncoding(t c.BaseType) int {
+	switch t {
+	case c.Char:
+		return ateSignedChar
+	case c.UChar:
+		return ateUnsignedChar
+	case c.UShort, c.UInt, c.ULong:
+		return ateUnsigned
+	case c.Float, c.Double:
+		return ateFloat
+	}
+	return ateSigned
+}
+
+// addrLoc returns the location expression of the given address.
+func addrLoc(addr uint32) []byte {
+	loc := []byte{opAddr}
+	return append(loc, byte(addr), byte(addr>>8), byte(addr>>16), byte(addr>>24))
+}
+
+// appendUleb appends the unsigned LEB128 encoding of x to buf.
+func appendUleb(buf []byte, x uint64) []byte {
+	for {
+		b := byte(x & 0x7F)
+		x >>= 7
+		if x != 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if x == 0 {
+			return buf
+		}
+	}
+}
+
+// appendSleb appends the signed LEB128 encoding of x to buf.
+func appendSleb(buf []byte, x int64) []byte {
+	for {
+		b := byte(x & 0x7F)
+		x >>= 7
+		done := (x == 0 && b&0x40 == 0) || (x == -1 && b&0x40 != 0)
+		if !done {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if done {
+			return buf
+		}
+	}
+}
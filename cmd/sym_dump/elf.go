@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mefistotelis/psx_mnd_sym/csym"
+	"github.com/mefistotelis/psx_mnd_sym/csym/c"
+	"github.com/pkg/errors"
+)
+
+// dumpELF outputs the symbols and debug information recorded by the parsers to
+// MIPS ELF files; one for the default binary stored at elfPath, and one for each
+// overlay stored next to it. The declarations of decls use the types of types.
+// The code of the default binary is copied from the PS-X EXE at exePath, if
+// given.
+func dumpELF(types, decls *csym.Parser, elfPath, exePath string) error {
+	var exe *psxExe
+	if len(exePath) > 0 {
+		var err error
+		if exe, err = parseExe(exePath); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	overlays := append([]*csym.Overlay{decls.Overlay}, decls.Overlays...)
+	for _, overlay := range overlays {
+		path := elfPath
+		var code *psxExe
+		if overlay.ID == 0 {
+			code = exe
+		} else {
+			ext := filepath.Ext(elfPath)
+			path = fmt.Sprintf("%s_overlay_%x%s", strings.TrimSuffix(elfPath, ext), overlay.ID, ext)
+		}
+		fmt.Println("creating:", path)
+		buf, err := buildELF(types, overlay, code)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+			return errors.Wrapf(err, "unable to create ELF file %q", path)
+		}
+	}
+	return nil
+}
+
+// A psxExe is the code of a PS-X EXE executable.
+type psxExe struct {
+	// Entry point.
+	PC uint32
+	// Load address of the code.
+	Addr uint32
+	// Code and data.
+	Data []byte
+}
+
+// PS-X EXE signature, and offsets of header fields.
+const (
+	exeMagic       = "PS-X EXE"
+	exeOffsetPC    = 0x10
+	exeOffsetTAddr = 0x18
+	exeOffsetTSize = 0x1C
+)
+
+// parseExe parses the given PS-X EXE executable.
+func parseExe(path string) (*psxExe, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(buf) < exeHeaderSize || string(buf[:len(exeMagic)]) != exeMagic {
+		return nil, errors.Errorf("invalid PS-X EXE %q; missing header", path)
+	}
+	exe := &psxExe{
+		PC:   binary.LittleEndian.Uint32(buf[exeOffsetPC:]),
+		Addr: binary.LittleEndian.Uint32(buf[exeOffsetTAddr:]),
+	}
+	size := binary.LittleEndian.Uint32(buf[exeOffsetTSize:])
+	if uint64(exeHeaderSize)+uint64(size) > uint64(len(buf)) {
+		return nil, errors.Errorf("invalid PS-X EXE %q; code size 0x%X exceeds file size", path, size)
+	}
+	exe.Data = buf[exeHeaderSize : exeHeaderSize+size]
+	return exe, nil
+}
+
+// An elfSection is a section of an ELF file being built.
+type elfSection struct {
+	// Section name.
+	name string
+	// Section header; the name and file offset are set when writing.
+	hdr elf.Section32
+	// Section contents.
+	data []byte
+}
+
+// ELF section indices.
+const (
+	elfText = 1 + iota
+	elfBSS
+	elfSymtab
+	elfStrtab
+	elfDebugAbbrev
+	elfDebugInfo
+	elfDebugLine
+	elfShstrtab
+	elfNumSections
+)
+
+// buildELF returns the contents of a MIPS little-endian ELF file holding the
+// symbols and debug information of the overlay, and the code of exe if not nil.
+func buildELF(types *csym.Parser, overlay *csym.Overlay, exe *psxExe) ([]byte, error) {
+	sections := make([]*elfSection, elfNumSections)
+	sections[0] = &elfSection{}
+	// Code section; covering the functions if no code is given.
+	text := &elfSection{
+		name: ".text",
+		hdr: elf.Section32{
+			Type:      uint32(elf.SHT_NOBITS),
+			Flags:     uint32(elf.SHF_ALLOC | elf.SHF_EXECINSTR),
+			Addralign: 4,
+		},
+	}
+	switch {
+	case exe != nil:
+		text.hdr.Type = uint32(elf.SHT_PROGBITS)
+		text.hdr.Flags |= uint32(elf.SHF_WRITE)
+		text.hdr.Addr = exe.Addr
+		text.hdr.Size = uint32(len(exe.Data))
+		text.data = exe.Data
+	case overlay.ID != 0:
+		text.hdr.Addr = overlay.Addr
+		text.hdr.Size = overlay.Length
+	default:
+		start, end := funcsRange(overlay.Funcs)
+		// Include code without debug information following the functions.
+		for _, symbol := range overlay.Untyped() {
+			if symbol.Kind == csym.SymbolCode && symbol.Addr >= end {
+				end = symbol.Addr + 4
+			}
+		}
+		text.hdr.Addr = start
+		text.hdr.Size = end - start
+	}
+	sections[elfText] = text
+	// Data section, covering the global variables outside of the code section.
+	bss := &elfSection{
+		name: ".bss",
+		hdr: elf.Section32{
+			Type:      uint32(elf.SHT_NOBITS),
+			Flags:     uint32(elf.SHF_ALLOC | elf.SHF_WRITE),
+			Addralign: 4,
+		},
+	}
+	var start, end uint32
+	vars := append(append([]*c.VarDecl(nil), overlay.Vars...), overlay.Statics...)
+	for _, v := range vars {
+		if inSection(text, v.Addr) {
+			continue
+		}
+		e := v.Addr + varSize(v)
+		if end == 0 || v.Addr < start {
+			start = v.Addr
+		}
+		if e > end {
+			end = e
+		}
+	}
+	bss.hdr.Addr = start
+	bss.hdr.Size = end - start
+	sections[elfBSS] = bss
+	// Symbol table.
+	symtab, strtab, nlocals := buildSymtab(overlay, sections)
+	sections[elfSymtab] = &elfSection{
+		name: ".symtab",
+		hdr: elf.Section32{
+			Type:      uint32(elf.SHT_SYMTAB),
+			Link:      elfStrtab,
+			Info:      uint32(1 + nlocals), // index of first non-local symbol.
+			Addralign: 4,
+			Entsize:   uint32(binary.Size(elf.Sym32{})),
+		},
+		data: symtab,
+	}
+	sections[elfStrtab] = &elfSection{
+		name: ".strtab",
+		hdr: elf.Section32{
+			Type:      uint32(elf.SHT_STRTAB),
+			Addralign: 1,
+		},
+		data: strtab,
+	}
+	// Debug information.
+	abbrev, info, line := buildDWARF(types, overlay, text.hdr.Addr, text.hdr.Addr+text.hdr.Size)
+	debugSections := []struct {
+		index int
+		name  string
+		data  []byte
+	}{
+		{index: elfDebugAbbrev, name: ".debug_abbrev", data: abbrev},
+		{index: elfDebugInfo, name: ".debug_info", data: info},
+		{index: elfDebugLine, name: ".debug_line", data: line},
+	}
+	for _, s := range debugSections {
+		sections[s.index] = &elfSection{
+			name: s.name,
+			hdr: elf.Section32{
+				Type:      uint32(elf.SHT_PROGBITS),
+				Addralign: 1,
+			},
+			data: s.data,
+		}
+	}
+	// Section names.
+	shstrtab := &elfSection{
+		name: ".shstrtab",
+		hdr: elf.Section32{
+			Type:      uint32(elf.SHT_STRTAB),
+			Addralign: 1,
+		},
+	}
+	sections[elfShstrtab] = shstrtab
+	names := newStrtab()
+	for _, s := range sections {
+		s.hdr.Name = names.add(s.name)
+	}
+	shstrtab.data = names.buf.Bytes()
+	return writeELF(sections, exe)
+}
+
+// writeELF returns the contents of an ELF file holding the given sections, with
+// a loadable segment for each allocated section.
+func writeELF(sections []*elfSection, exe *psxExe) ([]byte, error) {
+	var progs []elf.Prog32
+	hdrSize := uint32(binary.Size(elf.Header32{}))
+	progSize := uint32(binary.Size(elf.Prog32{}))
+	for _, s := range sections[1:] {
+		if s.hdr.Flags&uint32(elf.SHF_ALLOC) == 0 || s.hdr.Size == 0 {
+			continue
+		}
+		flags := elf.PF_R
+		if s.hdr.Flags&uint32(elf.SHF_WRITE) != 0 {
+			flags |= elf.PF_W
+		}
+		if s.hdr.Flags&uint32(elf.SHF_EXECINSTR) != 0 {
+			flags |= elf.PF_X
+		}
+		prog := elf.Prog32{
+			Type:  uint32(elf.PT_LOAD),
+			Flags: uint32(flags),
+			Vaddr: s.hdr.Addr,
+			Paddr: s.hdr.Addr,
+			Memsz: s.hdr.Size,
+			Align: 4,
+		}
+		progs = append(progs, prog)
+	}
+	// Lay out section contents after the file and program headers.
+	offset := hdrSize + uint32(len(progs))*progSize
+	for _, s := range sections[1:] {
+		offset = uint32(alignUp(int(offset), int(s.hdr.Addralign)))
+		s.hdr.Off = offset
+		if s.hdr.Type != uint32(elf.SHT_NOBITS) {
+			s.hdr.Size = uint32(len(s.data))
+			offset += s.hdr.Size
+		}
+	}
+	i := 0
+	for _, s := range sections[1:] {
+		if s.hdr.Flags&uint32(elf.SHF_ALLOC) == 0 || s.hdr.Size == 0 {
+			continue
+		}
+		progs[i].Off = s.hdr.Off
+		if s.hdr.Type != uint32(elf.SHT_NOBITS) {
+			progs[i].Filesz = s.hdr.Size
+		}
+		i++
+	}
+	shoff := uint32(alignUp(int(offset), 4))
+	hdr := elf.Header32{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_MIPS),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     shoff,
+		Flags:     elfFlagsO32,
+		Ehsize:    uint16(hdrSize),
+		Shentsize: uint16(binary.Size(elf.Section32{})),
+		Shnum:     uint16(len(sections)),
+		Shstrndx:  elfShstrtab,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS32)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	if exe != nil {
+		hdr.Entry = exe.PC
+	}
+	if len(progs) > 0 {
+		hdr.Phoff = hdrSize
+		hdr.Phentsize = uint16(progSize)
+		hdr.Phnum = uint16(len(progs))
+	}
+	buf := &bytes.Buffer{}
+	if err := binary.Write(buf, binary.LittleEndian, hdr); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, prog := range progs {
+		if err := binary.Write(buf, binary.LittleEndian, prog); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	for _, s := range sections[1:] {
+		if s.hdr.Type == uint32(elf.SHT_NOBITS) {
+			continue
+		}
+		buf.Write(make([]byte, int(s.hdr.Off)-buf.Len()))
+		buf.Write(s.data)
+	}
+	buf.Write(make([]byte, int(shoff)-buf.Len()))
+	for _, s := range sections {
+		if err := binary.Write(buf, binary.LittleEndian, s.hdr); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// MIPS ELF flags of the o32 ABI.
+const elfFlagsO32 = 0x00001000
+
+// buildSymtab returns the contents of the symbol table and its string table,
+// holding the functions, global variables and other symbols of the overlay,
+// and the number of local symbols; static variables, labels and symbols bound
+// locally, which precede the global symbols.
+func buildSymtab(overlay *csym.Overlay, sections []*elfSection) (symtab, strtab []byte, nlocals int) {
+	strs := newStrtab()
+	buf := &bytes.Buffer{}
+	type elfSym struct {
+		name string
+		sym  elf.Sym32
+	}
+	var syms []elfSym
+	seen := make(map[addrName]bool)
+	add := func(name string, addr, size uint32, bind elf.SymBind, typ elf.SymType) {
+		key := addrName{Addr: addr, Name: name}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		sym := elf.Sym32{
+			Value: addr,
+			Size:  size,
+			Info:  elf.ST_INFO(bind, typ),
+			Shndx: uint16(elf.SHN_ABS),
+		}
+		for i, s := range sections {
+			if i > 0 && s != nil && s.hdr.Flags&uint32(elf.SHF_ALLOC) != 0 && inSection(s, addr) {
+				sym.Shndx = uint16(i)
+				break
+			}
+		}
+		syms = append(syms, elfSym{name: name, sym: sym})
+	}
+	addAbs := func(name string, value uint32) {
+		sym := elf.Sym32{
+			Value: value,
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, elf.STT_NOTYPE),
+			Shndx: uint16(elf.SHN_ABS),
+		}
+		syms = append(syms, elfSym{name: name, sym: sym})
+	}
+	// Declarations named by local symbols are local.
+	locals := make(map[string]bool)
+	for _, symbol := range overlay.Symbols {
+		if symbol.Binding == csym.BindLocal {
+			locals[symbol.Name] = true
+		}
+	}
+	bind := func(local bool) elf.SymBind {
+		if local {
+			return elf.STB_LOCAL
+		}
+		return elf.STB_GLOBAL
+	}
+	for _, f := range overlay.Funcs {
+		add(f.Name, f.Addr, funcSize(f), bind(locals[f.Name]), elf.STT_FUNC)
+	}
+	for _, v := range overlay.Vars {
+		add(v.Name, v.Addr, varSize(v), bind(locals[v.Name] || v.Class == c.Static), elf.STT_OBJECT)
+	}
+	for _, v := range overlay.Statics {
+		add(v.Name, v.Addr, varSize(v), elf.STB_LOCAL, elf.STT_OBJECT)
+	}
+	for _, label := range overlay.Labels {
+		add(label.Name, label.Addr, 0, elf.STB_LOCAL, elf.STT_NOTYPE)
+	}
+	for _, symbol := range overlay.Untyped() {
+		local := symbol.Binding == csym.BindLocal
+		switch symbol.Kind {
+		case csym.SymbolCode:
+			add(symbol.Name, symbol.Addr, 0, bind(local), elf.STT_FUNC)
+		case csym.SymbolAbs:
+			addAbs(symbol.Name, symbol.Addr)
+		default:
+			add(symbol.Name, symbol.Addr, 0, bind(local), elf.STT_OBJECT)
+		}
+	}
+	// Local symbols precede global symbols.
+	sort.SliceStable(syms, func(i, j int) bool {
+		li := elf.ST_BIND(syms[i].sym.Info) == elf.STB_LOCAL
+		lj := elf.ST_BIND(syms[j].sym.Info) == elf.STB_LOCAL
+		if li != lj {
+			return li
+		}
+		return syms[i].sym.Value < syms[j].sym.Value
+	})
+	binary.Write(buf, binary.LittleEndian, elf.Sym32{})
+	for _, s := range syms {
+		if elf.ST_BIND(s.sym.Info) == elf.STB_LOCAL {
+			nlocals++
+		}
+		s.sym.Name = strs.add(s.name)
+		binary.Write(buf, binary.LittleEndian, s.sym)
+	}
+	return buf.Bytes(), strs.buf.Bytes(), nlocals
+}
+
+// A strtab is an ELF string table being built.
+type strtab struct {
+	// String table contents.
+	buf *bytes.Buffer
+	// offsets maps from strings to their offset in the table.
+	offsets map[string]uint32
+}
+
+// newStrtab returns a new string table, starting with the empty string.
+func newStrtab() *strtab {
+	t := &strtab{
+		buf:     &bytes.Buffer{},
+		offsets: make(map[string]uint32),
+	}
+	t.add("")
+	return t
+}
+
+// add adds the given string to the string table, and returns its offset.
+func (t *strtab) add(s string) uint32 {
+	if offset, ok := t.offsets[s]; ok {
+		return offset
+	}
+	offset := uint32(t.buf.Len())
+	t.buf.WriteString(s)
+	t.buf.WriteByte(0)
+	t.offsets[s] = offset
+	return offset
+}
+
+// ### [ Helper functions ] ####################################################
+
+// inSection reports whether the given address is within the section.
+func inSection(s *elfSection, addr uint32) bool {
+	return s.hdr.Size > 0 && addr >= s.hdr.Addr && addr-s.hdr.Addr < s.hdr.Size
+}
+
+// funcsRange returns the address range covered by the given functions.
+func funcsRange(funcs []*c.FuncDecl) (start, end uint32) {
+	for _, f := range funcs {
+		if end == 0 || f.Addr < start {
+			start = f.Addr
+		}
+		if e := f.Addr + funcSize(f); e > end {
+			end = e
+		}
+	}
+	return start, end
+}
+
+// alignUp rounds x up to a multiple of align.
+func alignUp(x, align int) int {
+	if align <= 1 {
+		return x
+	}
+	return (x + align - 1) / align * align
+}
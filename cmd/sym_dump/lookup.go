@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mefistotelis/psx_mnd_sym"
+	"github.com/mefistotelis/psx_mnd_sym/csym"
+	"github.com/mefistotelis/psx_mnd_sym/csym/c"
+	"github.com/pkg/errors"
+)
+
+// lookupUsage prints usage information of the lookup command.
+func lookupUsage() {
+	const use = `
+Usage: sym_dump lookup [OPTION]... FILE.SYM [ADDR|FILE:LINE]...
+
+Map addresses to functions and source lines, or source lines to addresses.
+Queries are read from standard input, one or more per line, if none are given.
+`
+	fmt.Println(use[1:])
+}
+
+// lookupMain runs the lookup command with the given command line arguments.
+func lookupMain(args []string) {
+	var opts sym.Options
+	fs := flag.NewFlagSet("lookup", flag.ExitOnError)
+	fs.BoolVar(&opts.Verbose, "v", false, "show verbose messages")
+	fs.BoolVar(&opts.Lenient, "lenient", false, "report problems and continue instead of aborting")
+	fs.Usage = func() {
+		lookupUsage()
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	path := fs.Arg(0)
+	f, err := sym.ParseFile(path, &opts)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	printDiags(path, f.Diags)
+	p := csym.NewParser(&opts)
+	p.ParseTypes(f.Syms)
+	p.ParseDecls(f.Syms)
+	printDiags(path, p.Diags)
+	idx, err := newLookupIndex(f, p)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	if fs.NArg() > 1 {
+		for _, query := range fs.Args()[1:] {
+			if err := idx.lookup(w, query, true); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		}
+		return
+	}
+	// Read queries from standard input; tokens which are neither addresses
+	// nor source lines are ignored, so that logs may be used directly.
+	s := bufio.NewScanner(os.Stdin)
+	for s.Scan() {
+		for _, query := range strings.Fields(s.Text()) {
+			if err := idx.lookup(w, query, false); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		}
+		// Show results as they come when used in a pipe.
+		if err := w.Flush(); err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+	}
+	if err := s.Err(); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// lookupIndex provides address lookups of functions and source lines.
+type lookupIndex struct {
+	// Overlays, starting with the default binary.
+	overlays []*lookupOverlay
+	// Overlays by ID.
+	overlayIDs map[uint32]*lookupOverlay
+	// Source lines of the symbol file.
+	lines *sym.LineTable
+}
+
+// lookupOverlay holds the functions of an overlay, sorted by address.
+type lookupOverlay struct {
+	*csym.Overlay
+	funcs []*c.FuncDecl
+}
+
+// newLookupIndex returns the lookup index of the given symbol file and the
+// declarations recorded by its parser.
+func newLookupIndex(f *sym.File, p *csym.Parser) (*lookupIndex, error) {
+	lines, err := sym.NewLineTable(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	idx := &lookupIndex{
+		overlayIDs: make(map[uint32]*lookupOverlay),
+		lines:      lines,
+	}
+	overlays := append([]*csym.Overlay{p.Overlay}, p.Overlays...)
+	for _, overlay := range overlays {
+		o := &lookupOverlay{
+			Overlay: overlay,
+			funcs:   append([]*c.FuncDecl(nil), overlay.Funcs...),
+		}
+		sort.SliceStable(o.funcs, func(i, j int) bool {
+			return o.funcs[i].Addr < o.funcs[j].Addr
+		})
+		idx.overlays = append(idx.overlays, o)
+		idx.overlayIDs[overlay.ID] = o
+	}
+	return idx, nil
+}
+
+// lookup resolves the query, either an address or a source line in FILE:LINE
+// format, writing the results to w. Invalid queries are reported as errors if
+// strict is set, and ignored otherwise.
+func (idx *lookupIndex) lookup(w io.Writer, query string, strict bool) error {
+	if !strict {
+		// Register dumps of logs (e.g. pc=80010000).
+		if i := strings.LastIndex(query, "="); i >= 0 {
+			query = query[i+1:]
+		}
+	}
+	if i := strings.LastIndex(query, ":"); i > 0 {
+		line, err := strconv.ParseUint(query[i+1:], 10, 32)
+		if err == nil {
+			return idx.lookupLine(w, query[:i], uint32(line))
+		}
+	}
+	addr, ok := parseAddr(query, strict)
+	if !ok {
+		if strict {
+			return errors.Errorf("invalid query %q; expected address or FILE:LINE", query)
+		}
+		return nil
+	}
+	return idx.lookupAddr(w, addr)
+}
+
+// parseAddr parses the given hexadecimal address, with an optional 0x prefix.
+// If strict is not set, only addresses with a 0x prefix or of exactly 8 digits
+// are accepted, so that words of logs (e.g. "add" or "10") are not mistaken for
+// addresses.
+func parseAddr(s string, strict bool) (uint32, bool) {
+	s = strings.TrimSuffix(strings.ToLower(s), ",")
+	hasPrefix := strings.HasPrefix(s, "0x")
+	s = strings.TrimPrefix(s, "0x")
+	if !strict && !hasPrefix && len(s) != 8 {
+		return 0, false
+	}
+	addr, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(addr), true
+}
+
+// lookupAddr writes the function and source line of the given address in each
+// overlay, to w.
+func (idx *lookupIndex) lookupAddr(w io.Writer, addr uint32) error {
+	found := false
+	for _, o := range idx.overlays {
+		f := o.funcAt(addr)
+		if f == nil {
+			continue
+		}
+		found = true
+		buf := &strings.Builder{}
+		fmt.Fprintf(buf, "0x%08X %s", addr, f.Name)
+		if addr != f.Addr {
+			fmt.Fprintf(buf, "+0x%X", addr-f.Addr)
+		}
+		if line := idx.lineAt(o, addr, f); line != nil {
+			fmt.Fprintf(buf, " %s:%d", line.Path, line.Line)
+		} else if len(f.Path) > 0 {
+			fmt.Fprintf(buf, " %s", f.Path)
+		}
+		if o.ID != 0 {
+			fmt.Fprintf(buf, " (overlay %x)", o.ID)
+		}
+		if _, err := fmt.Fprintln(w, buf.String()); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if !found {
+		if _, err := fmt.Fprintf(w, "0x%08X ??\n", addr); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// lookupLine writes the addresses of the given source line in each overlay, to
+// w.
+func (idx *lookupIndex) lookupLine(w io.Writer, path string, line uint32) error {
+	found := false
+	for _, e := range idx.lines.Entries {
+		if e.End || e.Line != line || !sym.PathMatch(e.Path, path) {
+			continue
+		}
+		found = true
+		buf := &strings.Builder{}
+		fmt.Fprintf(buf, "%s:%d 0x%08X", e.Path, e.Line, e.Addr)
+		if o, ok := idx.overlayIDs[e.Overlay]; ok {
+			if f := o.funcAt(e.Addr); f != nil {
+				fmt.Fprintf(buf, " %s", f.Name)
+				if e.Addr != f.Addr {
+					fmt.Fprintf(buf, "+0x%X", e.Addr-f.Addr)
+				}
+			}
+		}
+		if e.Overlay != 0 {
+			fmt.Fprintf(buf, " (overlay %x)", e.Overlay)
+		}
+		if _, err := fmt.Fprintln(w, buf.String()); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if !found {
+		if _, err := fmt.Fprintf(w, "%s:%d ??\n", path, line); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// funcAt returns the function containing the given address, or nil if not
+// found.
+func (o *lookupOverlay) funcAt(addr uint32) *c.FuncDecl {
+	// Index of the first function past the address.
+	i := sort.Search(len(o.funcs), func(i int) bool {
+		return o.funcs[i].Addr > addr
+	})
+	for i--; i >= 0; i-- {
+		f := o.funcs[i]
+		// Use the range of the function start and end symbols if present.
+		end := f.AddrEnd
+		if end == 0 && f.Size > 0 {
+			end = f.Addr + f.Size - 1
+		}
+		if addr <= end {
+			return f
+		}
+		// Functions may share an address; check the preceding ones too.
+		if i > 0 && o.funcs[i-1].Addr != f.Addr {
+			break
+		}
+	}
+	return nil
+}
+
+// lineAt returns the source line of the given address in the overlay, or nil
+// if not found. The line must be within the given function; addresses outside
+// of functions have no line.
+func (idx *lookupIndex) lineAt(o *lookupOverlay, addr uint32, f *c.FuncDecl) *sym.LineEntry {
+	if f == nil {
+		return nil
+	}
+	line, ok := idx.lines.Lookup(o.ID, addr)
+	if !ok || line.Addr < f.Addr {
+		return nil
+	}
+	return line
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mefistotelis/psx_mnd_sym"
+	"github.com/mefistotelis/psx_mnd_sym/csym"
+)
+
+func TestParseAddr(t *testing.T) {
+	golden := []struct {
+		s      string
+		strict bool
+		addr   uint32
+		ok     bool
+	}{
+		{s: "0x80010000", addr: 0x80010000, ok: true},
+		{s: "80010000", addr: 0x80010000, ok: true},
+		{s: "0x10,", addr: 0x10, ok: true},
+		{s: "add", ok: false},
+		{s: "be", ok: false},
+		{s: "10", ok: false},
+		{s: "8001000", ok: false},
+		{s: "800100000", ok: false},
+		{s: "0xzz", ok: false},
+		{s: "10", strict: true, addr: 0x10, ok: true},
+		{s: "add", strict: true, addr: 0xadd, ok: true},
+		{s: "main", strict: true, ok: false},
+	}
+	for _, g := range golden {
+		addr, ok := parseAddr(g.s, g.strict)
+		if ok != g.ok || addr != g.addr {
+			t.Errorf("%q (strict %v): address mismatch; expected 0x%X (%v), got 0x%X (%v)", g.s, g.strict, g.addr, g.ok, addr, ok)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	f := &sym.File{
+		Hdr: &sym.FileHeader{Signature: [3]byte{'M', 'N', 'D'}, Version: 1},
+		Syms: []*sym.Symbol{
+			{Hdr: &sym.SymbolHeader{Value: 0x80010000, Kind: sym.KindFuncStart}, Body: &sym.FuncStart{Line: 5, PathLen: 3, Path: "A.C", NameLen: 1, Name: "f"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010008, Kind: sym.KindBlockStart}, Body: &sym.BlockStart{Line: 2}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010010, Kind: sym.KindBlockEnd}, Body: &sym.BlockEnd{Line: 3}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010018, Kind: sym.KindFuncEnd}, Body: &sym.FuncEnd{Line: 8}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010040, Kind: sym.KindSetSLD2}, Body: &sym.SetSLD2{Line: 10, PathLen: 5, Path: "B.ASM"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010044, Kind: sym.KindIncSLD}, Body: &sym.IncSLD{}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010048, Kind: sym.KindEndSLD}, Body: &sym.EndSLD{}},
+		},
+	}
+	p := csym.NewParser(&sym.Options{})
+	p.ParseTypes(f.Syms)
+	p.ParseDecls(f.Syms)
+	idx, err := newLookupIndex(f, p)
+	if err != nil {
+		t.Fatalf("unable to create lookup index; %v", err)
+	}
+	golden := []struct {
+		query  string
+		strict bool
+		want   string
+	}{
+		{query: "0x80010000", strict: true, want: "0x80010000 f A.C:5\n"},
+		{query: "pc=8001000c", want: "0x8001000C f+0xC A.C:6\n"},
+		{query: "80010014", want: "0x80010014 f+0x14 A.C:7\n"},
+		{query: "80010018", want: "0x80010018 f+0x18 A.C:8\n"},
+		// Between functions.
+		{query: "0x80010020", strict: true, want: "0x80010020 ??\n"},
+		// Source lines outside of functions.
+		{query: "0x80010044", strict: true, want: "0x80010044 ??\n"},
+		{query: "add", want: ""},
+		{query: "10", want: ""},
+		{query: "a.c:6", strict: true, want: "A.C:6 0x80010008 f+0x8\n"},
+		{query: "b.asm:11", strict: true, want: "B.ASM:11 0x80010044\n"},
+		{query: "a.c:99", strict: true, want: "a.c:99 ??\n"},
+	}
+	for _, g := range golden {
+		buf := &bytes.Buffer{}
+		if err := idx.lookup(buf, g.query, g.strict); err != nil {
+			t.Errorf("%q: unable to lookup; %v", g.query, err)
+			continue
+		}
+		if got := buf.String(); got != g.want {
+			t.Errorf("%q: output mismatch; expected %q, got %q", g.query, g.want, got)
+		}
+	}
+	if err := idx.lookup(&bytes.Buffer{}, "main", true); err == nil {
+		t.Errorf("expected error for invalid strict query")
+	}
+}
@@ -1,5 +1,5 @@
 // The sym_dump tool converts Playstation 1 MND/SYM files to C headers (*.sym ->
-// *.h) and scripts for importing symbol information into IDA.
+// *.h) and scripts for importing symbol information into IDA, Ghidra, Binary Ninja and r2.
 package main
 
 import (
@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
-	"github.com/rickypai/natsort"
 	"github.com/mefistotelis/psx_mnd_sym"
 	"github.com/mefistotelis/psx_mnd_sym/csym"
 	"github.com/mefistotelis/psx_mnd_sym/csym/c"
@@ -19,7 +21,10 @@ import (
 // usage prints usage information.
 func usage() {
 	const use = `
-Convert Playstation 1 MND/SYM files to C headers (*.sym -> *.h) and scripts for importing symbol information into IDA.
+Convert Playstation 1 MND/SYM files to C headers (*.sym -> *.h) and scripts for importing symbol information into IDA, Ghidra, Binary Ninja and r2.
+
+Usage: sym_dump [OPTION]... FILE.SYM...
+   or: sym_dump lookup [OPTION]... FILE.SYM [ADDR|FILE:LINE]...
 `
 	fmt.Println(use[1:])
 	flag.PrintDefaults()
@@ -29,6 +34,11 @@ Convert Playstation 1 MND/SYM files to C headers (*.sym -> *.h) and scripts for
 const dumpDir = "_dump_"
 
 func main() {
+	// Subcommands.
+	if len(os.Args) > 1 && os.Args[1] == "lookup" {
+		lookupMain(os.Args[2:])
+		return
+	}
 	// Command line flags.
 	var (
 		// Output C types and declarations.
@@ -37,6 +47,27 @@ func main() {
 		outputDir string
 		// Output IDA scripts.
 		outputIDA bool
+		// Output Ghidra script.
+		outputGhidra bool
+		// Output Binary Ninja scripts.
+		outputBinja bool
+		// Output r2/rizin scripts.
+		outputR2 bool
+		// Output raw symbols in JSON format.
+		outputJSON bool
+		// Output C types and declarations in JSON format.
+		outputModel bool
+		// Output splat symbol addresses and configuration.
+		outputSplat bool
+		// Output no$psx symbol files.
+		outputNocash bool
+		// Output GNU ld map files.
+		outputMap bool
+		// Output ELF file path.
+		elfPath string
+		// PS-X EXE file path, of code included in ELF output and load address
+		// of splat output.
+		exePath string
 		// Merge SYM files.
 		merge bool
 		// Split output into source files.
@@ -45,18 +76,53 @@ func main() {
 		outputTypes bool
 		// Verbosity level.
 		opts sym.Options
+		// Symbols included in Psy-Q output.
+		filter sym.Filter
 	)
 	flag.BoolVar(&outputC, "c", false, "output C types and declarations")
 	flag.StringVar(&outputDir, "dir", dumpDir, "output directory")
 	flag.BoolVar(&outputIDA, "ida", false, "output IDA scripts")
+	flag.BoolVar(&outputGhidra, "ghidra", false, "output Ghidra script")
+	flag.BoolVar(&outputBinja, "binja", false, "output Binary Ninja scripts")
+	flag.BoolVar(&outputR2, "r2", false, "output r2/rizin scripts")
+	flag.BoolVar(&outputJSON, "json", false, "output symbols in JSON format")
+	flag.BoolVar(&outputModel, "json-model", false, "output C types and declarations in JSON format")
+	flag.BoolVar(&outputSplat, "splat", false, "output splat symbol addresses and configuration")
+	flag.BoolVar(&outputNocash, "nocash", false, "output no$psx symbol files")
+	flag.BoolVar(&outputMap, "map", false, "output GNU ld map files")
+	flag.StringVar(&elfPath, "elf", "", "output ELF file with symbols and DWARF debug information")
+	flag.StringVar(&exePath, "exe", "", "PS-X EXE file with code to include in ELF output, and load address of splat output")
 	flag.BoolVar(&merge, "merge", false, "merge SYM files")
 	flag.BoolVar(&splitSrc, "src", false, "split output into source files")
 	flag.BoolVar(&outputTypes, "types", false, "output C types")
 	flag.BoolVar(&opts.Verbose, "v", false, "show verbose messages")
+	flag.BoolVar(&opts.Lenient, "lenient", false, "report problems and continue instead of aborting")
+	opts.RawKinds = make(map[sym.Kind]int)
+	flag.Var(rawKinds(opts.RawKinds), "raw", "keep symbols of unknown kinds with the given body sizes (e.g. 0x9c:4,0x9e:8)")
+	filter.Kinds = make(map[sym.Kind]bool)
+	flag.Var(kindsFlag(filter.Kinds), "kind", "only list symbols of the given kinds in Psy-Q output (e.g. 8c,8e)")
+	flag.Var((*addrRange)(&filter), "addr", "only list symbols with values in the given range in Psy-Q output (e.g. 0x80010000-0x8001ffff)")
 	flag.Usage = usage
 	flag.Parse()
-	if merge && outputIDA {
-		log.Fatalf("IDA output not supported in merge mode, as the scripts would be unusable.")
+	if merge && len(exePath) > 0 {
+		log.Fatalf("PS-X EXE input not supported in merge mode, as the code of each SYM file differs.")
+	}
+	out := &dumpOptions{
+		dir:      outputDir,
+		c:        outputC,
+		types:    outputTypes,
+		ida:      outputIDA,
+		ghidra:   outputGhidra,
+		binja:    outputBinja,
+		r2:       outputR2,
+		model:    outputModel,
+		splat:    outputSplat,
+		nocash:   outputNocash,
+		ldMap:    outputMap,
+		elfPath:  elfPath,
+		exePath:  exePath,
+		splitSrc: splitSrc,
+		merge:    merge,
 	}
 
 	// Parse SYM files.
@@ -67,8 +133,9 @@ func main() {
 		if err != nil {
 			log.Fatalf("%+v", err)
 		}
+		printDiags(path, f.Diags)
 		switch {
-		case outputC, outputIDA:
+		case outputC, outputIDA, outputGhidra, outputBinja, outputR2, outputModel, outputSplat, outputNocash, outputMap, len(elfPath) > 0:
 			// Parse C types and declarations.
 			p := csym.NewParser(&opts)
 			if merge {
@@ -76,11 +143,12 @@ func main() {
 			}
 			p.ParseTypes(f.Syms)
 			p.ParseDecls(f.Syms)
+			printDiags(path, p.Diags)
 			p.RemoveDuplicateTypes()
 			p.MakeNamesUnique()
 			// Output once for each files if not in merge mode.
 			if !merge {
-				if err := dump(p, outputDir, outputC, outputTypes, outputIDA, splitSrc, merge); err != nil {
+				if err := dump(p, out); err != nil {
 					log.Fatalf("%+v", err)
 				}
 			}
@@ -91,279 +159,280 @@ func main() {
 				ps = append(ps, p)
 			}
 			p.ParseTypes(f.Syms)
+			printDiags(path, p.Diags)
 			// Output once for each files if not in merge mode.
 			if !merge {
-				if err := dump(p, outputDir, outputC, outputTypes, outputIDA, splitSrc, merge); err != nil {
+				if err := dump(p, out); err != nil {
 					log.Fatalf("%+v", err)
 				}
 			}
+		case outputJSON:
+			// Output symbols in JSON format.
+			// Note, we never merge the JSON output of symbols.
+			if err := f.WriteJSON(os.Stdout); err != nil {
+				log.Fatalf("%+v", err)
+			}
 		default:
 			// Output in Psy-Q DUMPSYM.EXE format.
 			// Note, we never merge the Psy-Q output.
-			fmt.Print(f)
+			if err := f.DumpFilter(os.Stdout, &filter); err != nil {
+				log.Fatalf("%+v", err)
+			}
 		}
 	}
 	// Output the merge of all files if in merge mode.
 	if merge {
-		skipAddrDiff := true
-		skipLineDiff := true
-		p := pruneDuplicates(ps, skipAddrDiff, skipLineDiff, &opts)
-		if err := dump(p, outputDir, outputC, outputTypes, outputIDA, splitSrc, merge); err != nil {
+		mergeOpts := csym.MergeOptions{
+			SkipAddrDiff: true,
+			SkipLineDiff: true,
+		}
+		p, err := csym.Merge(ps, mergeOpts)
+		if err != nil {
 			log.Fatalf("%+v", err)
 		}
+		if err := dump(p, out); err != nil {
+			log.Fatalf("%+v", err)
+		}
+		switch {
+		case outputIDA:
+			// Output IDA scripts of each file, using the merged types.
+			if err := dumpInputs(p, outputDir, flag.Args(), dumpIDAScripts); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		case outputGhidra:
+			// Output Ghidra script of each file, using the merged types.
+			dumpInput := func(in *csym.Parser, dir string) error {
+				return dumpGhidraScript(p, in, dir)
+			}
+			if err := dumpInputs(p, outputDir, flag.Args(), dumpInput); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		case outputBinja:
+			// Output Binary Ninja scripts of each file, using the merged types.
+			dumpInput := func(in *csym.Parser, dir string) error {
+				return dumpBinjaScripts(p, in, dir)
+			}
+			if err := dumpInputs(p, outputDir, flag.Args(), dumpInput); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		case outputR2:
+			// Output r2 scripts of each file, using the merged types.
+			dumpInput := func(in *csym.Parser, dir string) error {
+				return dumpR2Scripts(p, in, dir)
+			}
+			if err := dumpInputs(p, outputDir, flag.Args(), dumpInput); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		case outputSplat:
+			// Output splat files of each file.
+			dumpInput := func(in *csym.Parser, dir string) error {
+				return dumpSplat(in, dir, "")
+			}
+			if err := dumpInputs(p, outputDir, flag.Args(), dumpInput); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		case outputNocash:
+			// Output no$psx symbol files of each file.
+			if err := dumpInputs(p, outputDir, flag.Args(), dumpNocash); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		case outputMap:
+			// Output GNU ld map files of each file.
+			if err := dumpInputs(p, outputDir, flag.Args(), dumpMap); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		case len(elfPath) > 0:
+			// Output ELF files of each file, using the merged types, stored in
+			// subdirectories of the output directory.
+			dumpInput := func(in *csym.Parser, dir string) error {
+				return dumpELF(p, in, filepath.Join(dir, filepath.Base(elfPath)), "")
+			}
+			if err := initOutputDir(outputDir); err != nil {
+				log.Fatalf("%+v", err)
+			}
+			if err := dumpInputs(p, outputDir, flag.Args(), dumpInput); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		}
 	}
 }
 
-// placeholder type name to make types match even when typename differ.
-const placeholder = "placeholder"
+// rawKinds is a command line flag specifying the body sizes of symbol kinds
+// unknown to the parser, as a comma-separated list of kind:size pairs.
+type rawKinds map[sym.Kind]int
 
-// pruneDuplicates prunes duplicates declarations of the parser, optionally
-// ignoring differences in address.
-func pruneDuplicates(ps []*csym.Parser, skipAddrDiff, skipLineDiff bool, opts *sym.Options) *csym.Parser {
-	dst := csym.NewParser(opts)
-	enumPresent := make(map[string]bool)
-	structPresent := make(map[string]bool)
-	unionPresent := make(map[string]bool)
-	typeDefPresent := make(map[string]bool)
-	// Add unique predeclared identifiers.
-	if def, ok := ps[0].Types["bool"]; ok {
-		dst.Types["bool"] = def
+// String returns the string representation of the flag value.
+func (r rawKinds) String() string {
+	var pairs []string
+	for kind, size := range r {
+		pairs = append(pairs, fmt.Sprintf("0x%02x:%d", uint8(kind), size))
 	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
 
-	fakeEnum := 0
-	fakeStruct := 0
-	fakeUnion := 0
-	for pnum, p := range ps {
-		addUniqueEnums(dst, p, pnum, &fakeEnum, &enumPresent, opts)
-		addUniqueStructs(dst, p, pnum, &fakeStruct, &structPresent, opts)
-		addUniqueUnions(dst, p, pnum, &fakeUnion, &unionPresent, opts)
-		// Add unique typedefs.
-		for _, def := range p.Typedefs {
-			s := def.Def()
-			if _, ok := typeDefPresent[s]; !ok {
-				dst.Typedefs = append(dst.Typedefs, def)
-			}
-			typeDefPresent[s] = true
+// Set parses the flag value.
+func (r rawKinds) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return errors.Errorf("invalid raw symbol kind %q; expected kind:size", pair)
+		}
+		kind, err := strconv.ParseUint(parts[0], 0, 8)
+		if err != nil {
+			return errors.Wrapf(err, "invalid symbol kind %q", parts[0])
+		}
+		size, err := strconv.ParseUint(parts[1], 0, 16)
+		if err != nil {
+			return errors.Wrapf(err, "invalid symbol body size %q", parts[1])
 		}
+		r[sym.Kind(kind)] = int(size)
 	}
+	return nil
+}
+
+// kindsFlag is a command line flag specifying a comma-separated list of
+// symbol kinds, in hexadecimal.
+type kindsFlag map[sym.Kind]bool
 
-	// Sort types by tag.
-	less := func(i, j int) bool {
-		ti := dst.Typedefs[i].(*c.VarDecl)
-		tj := dst.Typedefs[j].(*c.VarDecl)
-		return natsort.Less(ti.Name, tj.Name)
+// String returns the string representation of the flag value.
+func (k kindsFlag) String() string {
+	var kinds []string
+	for kind := range k {
+		kinds = append(kinds, fmt.Sprintf("%02x", uint8(kind)))
 	}
-	sort.Slice(dst.Typedefs, less)
+	sort.Strings(kinds)
+	return strings.Join(kinds, ",")
+}
 
-	varDeclPresent := make(map[string]bool)
-	funcDeclPresent := make(map[string]bool)
-	dstOverlays := make(map[uint32]*csym.Overlay)
-	for _, p := range ps {
-		// Add unique declarations of each overlay.
-		overlays := append(p.Overlays, p.Overlay)
-		for _, overlay := range overlays {
-			curOverlay, ok := dstOverlays[overlay.ID]
-			if !ok {
-				curOverlay = &csym.Overlay{
-					ID: overlay.ID,
-				}
-				dstOverlays[overlay.ID] = curOverlay
-				if overlay.ID == p.Overlay.ID {
-					if len(dst.Overlay.Vars) > 0 {
-						panic("unreachable") // I hope :)
-					}
-					dst.Overlay = curOverlay
-				} else {
-					dst.Overlays = append(dst.Overlays, curOverlay)
-				}
-			}
-			// Add unique variable declarations.
-			for _, v := range overlay.Vars {
-				origAddr := v.Addr
-				if skipAddrDiff {
-					v.Addr = 0
-				}
-				s := v.Def()
-				if skipAddrDiff {
-					v.Addr = origAddr
-				}
-				if !varDeclPresent[s] {
-					curOverlay.Vars = append(curOverlay.Vars, v)
-				}
-				varDeclPresent[s] = true
-			}
-			// Add unique function declarations.
-			for _, f := range overlay.Funcs {
-				origAddr := f.Addr
-				origLineStart := f.LineStart
-				origLineEnd := f.LineEnd
-				if skipAddrDiff {
-					f.Addr = 0
-				}
-				if skipLineDiff {
-					f.LineStart = 0
-					f.LineEnd = 0
-				}
-				s := f.Def()
-				if skipAddrDiff {
-					f.Addr = origAddr
-				}
-				if skipLineDiff {
-					f.LineStart = origLineStart
-					f.LineEnd = origLineEnd
-				}
-				if !funcDeclPresent[s] {
-					curOverlay.Funcs = append(curOverlay.Funcs, f)
-				}
-				funcDeclPresent[s] = true
-			}
+// Set parses the flag value.
+func (k kindsFlag) Set(s string) error {
+	for _, kind := range strings.Split(s, ",") {
+		v, err := strconv.ParseUint(strings.TrimPrefix(kind, "0x"), 16, 8)
+		if err != nil {
+			return errors.Wrapf(err, "invalid symbol kind %q", kind)
 		}
+		k[sym.Kind(v)] = true
 	}
+	return nil
+}
 
-	// Sort variable and function declarations by name.
-	overlays := append(dst.Overlays, dst.Overlay)
-	for _, overlay := range overlays {
-		// Sort variable declarations.
-		less := func(i, j int) bool {
-			return natsort.Less(overlay.Vars[i].Name, overlay.Vars[j].Name)
-		}
-		sort.Slice(overlay.Vars, less)
-		// Sort function declarations.
-		less = func(i, j int) bool {
-			return natsort.Less(overlay.Funcs[i].Name, overlay.Funcs[j].Name)
-		}
-		sort.Slice(overlay.Funcs, less)
-	}
+// addrRange is a command line flag specifying a range of symbol values, as
+// start-end.
+type addrRange sym.Filter
 
-	return dst
+// String returns the string representation of the flag value.
+func (r *addrRange) String() string {
+	if r == nil || (r.Start == 0 && r.End == 0) {
+		return ""
+	}
+	return fmt.Sprintf("0x%08x-0x%08x", r.Start, r.End)
 }
 
-// addUniqueStructs adds unique structs to destination parser.
-func addUniqueStructs(dst *csym.Parser, p *csym.Parser, pnum int, fakeCount *int, isPresent *map[string]bool, opts *sym.Options) {
-	for tag, structs := range p.StructTags {
-		for i := 0; i < len(structs); i++ {
-			t := structs[i]
-			fake := c.IsFakeTag(t.Tag)
-			if fake {
-				t.Tag = placeholder
-			}
-			s := t.Def()
-			if fake {
-				tag = fmt.Sprintf("struct_fake_%d_%d", pnum, *fakeCount)
-				(*fakeCount)++
-				t.Tag = tag
-			}
-			if !(*isPresent)[s] {
-				if !fake {
-					if _, ok := dst.StructTags[tag]; ok {
-						tag = fmt.Sprintf("%s_dup_%d", tag, pnum)
-						t.Tag = tag
-					}
-				}
-				dst.StructTags[tag] = append(dst.StructTags[tag], t)
-				dst.Structs = append(dst.Structs, t)
-			}
-			(*isPresent)[s] = true
-		}
+// Set parses the flag value.
+func (r *addrRange) Set(s string) error {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return errors.Errorf("invalid address range %q; expected start-end", s)
+	}
+	start, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		return errors.Wrapf(err, "invalid start address %q", parts[0])
+	}
+	end, err := strconv.ParseUint(parts[1], 0, 32)
+	if err != nil {
+		return errors.Wrapf(err, "invalid end address %q", parts[1])
 	}
+	r.Start = uint32(start)
+	r.End = uint32(end)
+	return nil
 }
 
-// addUniqueUnions adds unique unions to destination parser.
-func addUniqueUnions(dst *csym.Parser, p *csym.Parser, pnum int, fakeCount *int, isPresent *map[string]bool, opts *sym.Options) {
-	for tag, unions := range p.UnionTags {
-		for i := 0; i < len(unions); i++ {
-			t := unions[i]
-			fake := c.IsFakeTag(t.Tag)
-			if fake {
-				t.Tag = placeholder
-			}
-			s := t.Def()
-			if fake {
-				tag = fmt.Sprintf("union_fake_%d_%d", pnum, *fakeCount)
-				(*fakeCount)++
-				t.Tag = tag
-			}
-			if !(*isPresent)[s] {
-				if !fake {
-					if _, ok := dst.UnionTags[tag]; ok {
-						tag = fmt.Sprintf("%s_dup_%d", tag, pnum)
-						t.Tag = tag
-					}
-				}
-				dst.UnionTags[tag] = append(dst.UnionTags[tag], t)
-				dst.Unions = append(dst.Unions, t)
-			}
-			(*isPresent)[s] = true
-		}
+// printDiags prints the problems found while parsing the given SYM file in
+// lenient mode.
+func printDiags(path string, diags []*sym.Diagnostic) {
+	for _, diag := range diags {
+		log.Printf("%s: %v", path, diag)
 	}
 }
 
-// addUniqueEnums adds unique Enums to destination parser.
-func addUniqueEnums(dst *csym.Parser, p *csym.Parser, pnum int, fakeCount *int, isPresent *map[string]bool, opts *sym.Options) {
-	for tag, enums := range p.EnumTags {
-		for i := 0; i < len(enums); i++ {
-			t := enums[i]
-			fake := c.IsFakeTag(t.Tag)
-			if fake {
-				t.Tag = placeholder
-			}
-			s := t.Def()
-			if fake {
-				tag = fmt.Sprintf("enum_fake_%d_%d", pnum, *fakeCount)
-				(*fakeCount)++
-				t.Tag = tag
-			}
-			if !(*isPresent)[s] {
-				if !fake {
-					if _, ok := dst.EnumTags[tag]; ok {
-						tag = fmt.Sprintf("%s_dup_%d", tag, pnum)
-						t.Tag = tag
-					}
-				}
-				dst.EnumTags[tag] = append(dst.EnumTags[tag], t)
-				dst.Enums = append(dst.Enums, t)
-			}
-			(*isPresent)[s] = true
-		}
-	}
+// dumpOptions specifies the output formats of dump.
+type dumpOptions struct {
+	// Output directory.
+	dir string
+	// Output C types and declarations.
+	c bool
+	// Output C types.
+	types bool
+	// Output IDA scripts.
+	ida bool
+	// Output Ghidra script.
+	ghidra bool
+	// Output Binary Ninja scripts.
+	binja bool
+	// Output r2/rizin scripts.
+	r2 bool
+	// Output C types and declarations in JSON format.
+	model bool
+	// Output splat symbol addresses and configuration.
+	splat bool
+	// Output no$psx symbol files.
+	nocash bool
+	// Output GNU ld map files.
+	ldMap bool
+	// Output ELF file path.
+	elfPath string
+	// PS-X EXE file path, of code included in ELF output and load address of
+	// splat output; not supported in merge mode.
+	exePath string
+	// Split output into source files.
+	splitSrc bool
+	// Merge SYM files.
+	merge bool
 }
 
-// dump dumps the declarations of the parser to the given output directory, in
-// the format specified.
-func dump(p *csym.Parser, outputDir string, outputC, outputTypes, outputIDA, splitSrc, merge bool) error {
+// dump dumps the declarations of the parser to the output directory, in the
+// format specified.
+//
+// In merge mode, outputs tied to addresses (IDA, Ghidra, Binary Ninja, r2,
+// splat, no$psx, ld map and ELF) are skipped here and created for each input
+// instead, as the merged declarations mix addresses of different files.
+func dump(p *csym.Parser, out *dumpOptions) error {
 	switch {
-	case outputC:
+	case out.c:
 		// Output C types and declarations.
-		if err := initOutputDir(outputDir); err != nil {
+		if err := initOutputDir(out.dir); err != nil {
 			return errors.WithStack(err)
 		}
-		if err := dumpTypes(p, outputDir); err != nil {
+		if err := dumpTypes(p, out.dir, true); err != nil {
 			return errors.WithStack(err)
 		}
-		if splitSrc {
-			if err := dumpSourceFiles(p, outputDir); err != nil {
+		if out.splitSrc {
+			if err := dumpSourceFiles(p, out.dir); err != nil {
 				return errors.WithStack(err)
 			}
 		} else {
-			if err := dumpDecls(p, outputDir); err != nil {
+			if err := dumpDecls(p, out.dir); err != nil {
 				return errors.WithStack(err)
 			}
 		}
-	case outputTypes:
+	case out.types:
 		// Output C types.
-		if err := initOutputDir(outputDir); err != nil {
+		if err := initOutputDir(out.dir); err != nil {
 			return errors.WithStack(err)
 		}
-		if err := dumpTypes(p, outputDir); err != nil {
+		if err := dumpTypes(p, out.dir, true); err != nil {
 			return errors.WithStack(err)
 		}
-	case outputIDA:
+	case out.ida:
 		// Output IDA scripts.
-		if err := initOutputDir(outputDir); err != nil {
+		if err := initOutputDir(out.dir); err != nil {
 			return errors.WithStack(err)
 		}
-		if err := dumpIDAScripts(p, outputDir); err != nil {
-			return errors.WithStack(err)
+		if !out.merge {
+			if err := dumpIDAScripts(p, out.dir); err != nil {
+				return errors.WithStack(err)
+			}
 		}
 		// Delete bool and __int64 types as they cause issues with IDA.
 		delete(p.Types, "bool")
@@ -377,9 +446,84 @@ func dump(p *csym.Parser, outputDir string, outputC, outputTypes, outputIDA, spl
 			}
 		}
 		delete(p.Types, "__int64")
-		if err := dumpTypes(p, outputDir); err != nil {
+		if err := dumpTypes(p, out.dir, false); err != nil {
+			return errors.WithStack(err)
+		}
+	case out.ghidra:
+		// Output Ghidra script.
+		if err := initOutputDir(out.dir); err != nil {
+			return errors.WithStack(err)
+		}
+		if !out.merge {
+			if err := dumpGhidraScript(p, p, out.dir); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case out.binja:
+		// Output Binary Ninja scripts.
+		if err := initOutputDir(out.dir); err != nil {
+			return errors.WithStack(err)
+		}
+		if !out.merge {
+			if err := dumpBinjaScripts(p, p, out.dir); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case out.r2:
+		// Output r2/rizin scripts.
+		if err := initOutputDir(out.dir); err != nil {
+			return errors.WithStack(err)
+		}
+		if !out.merge {
+			if err := dumpR2Scripts(p, p, out.dir); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case out.model:
+		// Output C types and declarations in JSON format.
+		if err := initOutputDir(out.dir); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := dumpModel(p, out.dir); err != nil {
+			return errors.WithStack(err)
+		}
+	case out.splat:
+		// Output splat symbol addresses and configuration.
+		if err := initOutputDir(out.dir); err != nil {
+			return errors.WithStack(err)
+		}
+		if !out.merge {
+			if err := dumpSplat(p, out.dir, out.exePath); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case out.nocash:
+		// Output no$psx symbol files.
+		if err := initOutputDir(out.dir); err != nil {
+			return errors.WithStack(err)
+		}
+		if !out.merge {
+			if err := dumpNocash(p, out.dir); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case out.ldMap:
+		// Output GNU ld map files.
+		if err := initOutputDir(out.dir); err != nil {
 			return errors.WithStack(err)
 		}
+		if !out.merge {
+			if err := dumpMap(p, out.dir); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case len(out.elfPath) > 0:
+		// Output ELF files.
+		if !out.merge {
+			if err := dumpELF(p, p, out.elfPath, out.exePath); err != nil {
+				return errors.WithStack(err)
+			}
+		}
 	}
 	return nil
 }
@@ -3,9 +3,12 @@ package main
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -20,8 +23,9 @@ import (
 const typesName = "types.h"
 
 // dumpTypes outputs the type information recorded by the parser to a C header
-// stored in the output directory.
-func dumpTypes(p *csym.Parser, outputDir string) error {
+// stored in the output directory. Static assertions checking the layout of
+// structs and unions are added if asserts is set.
+func dumpTypes(p *csym.Parser, outputDir string, asserts bool) error {
 	// Create output file.
 	typesPath := filepath.Join(outputDir, typesName)
 	fmt.Println("creating:", typesPath)
@@ -30,34 +34,60 @@ func dumpTypes(p *csym.Parser, outputDir string) error {
 		return errors.WithStack(err)
 	}
 	defer f.Close()
+	if err := writeTypes(f, p, asserts); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// writeTypes writes the type information recorded by the parser as C source to
+// w. Static assertions checking the layout of structs and unions are added if
+// asserts is set.
+func writeTypes(w io.Writer, p *csym.Parser, asserts bool) error {
+	if asserts {
+		if _, err := fmt.Fprintf(w, "#include <stddef.h>\n\n"); err != nil {
+			return errors.WithStack(err)
+		}
+	}
 	// Print predeclared identifiers.
 	if def, ok := p.Types["bool"]; ok {
-		if _, err := fmt.Fprintf(f, "%s;\n\n", def.Def()); err != nil {
+		if _, err := fmt.Fprintf(w, "%s;\n\n", def.Def()); err != nil {
 			return errors.WithStack(err)
 		}
 	}
-	// Print enums.
-	for _, t := range p.Enums {
-		if _, err := fmt.Fprintf(f, "%s;\n\n", t.Def()); err != nil {
+	// Print types ordered by dependency, with forward declarations of the
+	// types used through pointers before being defined.
+	order := p.OrderTypes()
+	for _, cycle := range order.Cycles {
+		log.Printf("type dependency cycle: %s", csym.CycleString(cycle))
+	}
+	for _, t := range order.Forward {
+		if _, err := fmt.Fprintf(w, "%s;\n", t); err != nil {
 			return errors.WithStack(err)
 		}
 	}
-	// Print structs.
-	for _, t := range p.Structs {
-		if _, err := fmt.Fprintf(f, "%s;\n\n", t.Def()); err != nil {
+	if len(order.Forward) > 0 {
+		if _, err := fmt.Fprintln(w); err != nil {
 			return errors.WithStack(err)
 		}
 	}
-	// Print unions.
-	for _, t := range p.Unions {
-		if _, err := fmt.Fprintf(f, "%s;\n\n", t.Def()); err != nil {
+	for _, t := range order.Defs {
+		if _, err := fmt.Fprintf(w, "%s;\n\n", t.Def()); err != nil {
 			return errors.WithStack(err)
 		}
 	}
-	// Print typedefs.
-	for _, def := range p.Typedefs {
-		if _, err := fmt.Fprintf(f, "%s;\n\n", def.Def()); err != nil {
-			return errors.WithStack(err)
+	if !asserts {
+		return nil
+	}
+	// Print layout checks.
+	if _, err := fmt.Fprintf(w, "// Layout checks.\n"); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, t := range order.Defs {
+		for _, assert := range c.LayoutAsserts(t) {
+			if _, err := fmt.Fprintf(w, "%s;\n", assert); err != nil {
+				return errors.WithStack(err)
+			}
 		}
 	}
 	return nil
@@ -115,6 +145,15 @@ func dumpOverlay(w io.Writer, overlay *csym.Overlay) error {
 			return errors.WithStack(err)
 		}
 	}
+	// Print declarations of symbols without type information.
+	for _, symbol := range overlay.Untyped() {
+		if !isIdent(symbol.Name) {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\n\n", untypedDef(symbol)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
 	// Print variable declarations.
 	for _, v := range overlay.Vars {
 		if _, err := fmt.Fprintf(w, "%s;\n\n", v.Def()); err != nil {
@@ -130,6 +169,35 @@ func dumpOverlay(w io.Writer, overlay *csym.Overlay) error {
 	return nil
 }
 
+// untypedDef returns the C declaration of the given symbol without type
+// information; an array of unknown type and length, as commonly used for
+// linker symbols.
+func untypedDef(symbol *csym.Symbol) string {
+	switch symbol.Kind {
+	case csym.SymbolAbs:
+		return fmt.Sprintf("// value: 0x%08X (absolute)\nextern char %s[];", symbol.Addr, symbol.Name)
+	case csym.SymbolCode:
+		return fmt.Sprintf("// address: 0x%08X (code)\nextern char %s[];", symbol.Addr, symbol.Name)
+	}
+	return fmt.Sprintf("// address: 0x%08X\nextern char %s[];", symbol.Addr, symbol.Name)
+}
+
+// isIdent reports whether the given name is a valid C identifier.
+func isIdent(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', r == '_':
+		case '0' <= r && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // --- [ Source files ] --------------------------------------------------------
 
 // A SourceFile is a source file.
@@ -214,13 +282,48 @@ func dumpSourceFile(w io.Writer, src *SourceFile) error {
 // dumpIDAScripts outputs the declarations recorded by the parser to IDA scripts
 // stored in the output directory.
 func dumpIDAScripts(p *csym.Parser, outputDir string) error {
+	return dumpOverlays(p, outputDir, dumpIDAOverlay)
+}
+
+// dumpOverlays outputs the declarations of the default binary and of each
+// overlay of the parser using dumpOverlay, with the output of overlays stored in
+// subdirectories of the output directory.
+func dumpOverlays(p *csym.Parser, outputDir string, dumpOverlay func(overlay *csym.Overlay, dir string) error) error {
 	// Create scripts for declarations of default binary.
-	if err := dumpIDAOverlay(p.Overlay, outputDir); err != nil {
+	if err := dumpOverlay(p.Overlay, outputDir); err != nil {
 		return errors.WithStack(err)
 	}
 	// Create scripts for declarations of overlays.
 	for _, overlay := range p.Overlays {
-		if err := dumpIDAOverlay(overlay, outputDir); err != nil {
+		overlayDir := fmt.Sprintf("overlay_%x", overlay.ID)
+		dir := filepath.Join(outputDir, overlayDir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := dumpOverlay(overlay, dir); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// dumpInputs outputs the declarations of each input of the merged parser to a
+// subdirectory of the output directory, named after the given SYM file paths
+// of the inputs.
+func dumpInputs(p *csym.Parser, outputDir string, paths []string, dumpInput func(in *csym.Parser, dir string) error) error {
+	used := make(map[string]bool)
+	for i, in := range p.Inputs {
+		base := strings.TrimSuffix(filepath.Base(paths[i]), filepath.Ext(paths[i]))
+		name := base
+		for j := 1; used[strings.ToLower(name)]; j++ {
+			name = fmt.Sprintf("%s_%d", base, j)
+		}
+		used[strings.ToLower(name)] = true
+		dir := filepath.Join(outputDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := dumpInput(in, dir); err != nil {
 			return errors.WithStack(err)
 		}
 	}
@@ -237,17 +340,10 @@ const (
 	idaVarsName = "set_vars.py"
 )
 
-// dumpIDAOverlay outputs the declarations of the overlay to IDA scripts.
-func dumpIDAOverlay(overlay *csym.Overlay, outputDir string) error {
+// dumpIDAOverlay outputs the declarations of the overlay to IDA scripts stored
+// in the given directory.
+func dumpIDAOverlay(overlay *csym.Overlay, dir string) error {
 	// Create scripts for mapping addresses to identifiers.
-	dir := outputDir
-	if overlay.ID != 0 {
-		overlayDir := fmt.Sprintf("overlay_%x", overlay.ID)
-		dir = filepath.Join(outputDir, overlayDir)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return errors.WithStack(err)
-		}
-	}
 	identsPath := filepath.Join(dir, idaIdentsName)
 	fmt.Println("creating:", identsPath)
 	w, err := os.Create(identsPath)
@@ -255,15 +351,52 @@ func dumpIDAOverlay(overlay *csym.Overlay, outputDir string) error {
 		return errors.Wrapf(err, "unable to create declarations IDA script %q", identsPath)
 	}
 	defer w.Close()
+	// Names of global symbols, or declared extern, are made public.
+	globals := make(map[string]bool)
+	for _, symbol := range overlay.Symbols {
+		if symbol.Binding == csym.BindGlobal {
+			globals[symbol.Name] = true
+		}
+	}
 	for _, f := range overlay.Funcs {
 		if _, err := fmt.Fprintf(w, "set_name(0x%08X, %q, SN_NOWARN)\n", f.Addr, f.Name); err != nil {
 			return errors.WithStack(err)
 		}
+		if globals[f.Name] {
+			if _, err := fmt.Fprintf(w, "make_name_public(0x%08X)\n", f.Addr); err != nil {
+				return errors.WithStack(err)
+			}
+		}
 	}
 	for _, v := range overlay.Vars {
 		if _, err := fmt.Fprintf(w, "set_name(0x%08X, %q, SN_NOWARN)\n", v.Addr, v.Name); err != nil {
 			return errors.WithStack(err)
 		}
+		if v.Class == c.Extern || globals[v.Name] {
+			if _, err := fmt.Fprintf(w, "make_name_public(0x%08X)\n", v.Addr); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	for _, v := range overlay.Statics {
+		if _, err := fmt.Fprintf(w, "set_name(0x%08X, %q, SN_NOWARN)\n", v.Addr, v.Name); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, label := range overlay.Labels {
+		if _, err := fmt.Fprintf(w, "set_name(0x%08X, %q, SN_NOWARN)\n", label.Addr, label.Name); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, symbol := range overlay.UntypedAddrs() {
+		if _, err := fmt.Fprintf(w, "set_name(0x%08X, %q, SN_NOWARN)\n", symbol.Addr, symbol.Name); err != nil {
+			return errors.WithStack(err)
+		}
+		if symbol.Binding == csym.BindGlobal {
+			if _, err := fmt.Fprintf(w, "make_name_public(0x%08X)\n", symbol.Addr); err != nil {
+				return errors.WithStack(err)
+			}
+		}
 	}
 	// Create scripts for adding function signatures to identifiers.
 	funcsPath := filepath.Join(dir, idaFuncsName)
@@ -277,9 +410,23 @@ func dumpIDAOverlay(overlay *csym.Overlay, outputDir string) error {
 		if _, err := fmt.Fprintf(w, "del_items(0x%08X)\n", f.Addr); err != nil {
 			return errors.WithStack(err)
 		}
-		if _, err := fmt.Fprintf(w, "SetType(0x%08X, %q)\n", f.Addr, f.Var); err != nil {
+		if _, err := fmt.Fprintf(w, "SetType(0x%08X, %q)\n", f.Addr, idaFuncType(f)); err != nil {
 			return errors.WithStack(err)
 		}
+		// Name the saved registers and variables of the stack frame. Their
+		// locations are given in the "[bp+xx]" form of define_local_var, with
+		// offsets relative to the frame pointer of IDA, which is the top of the
+		// frame (the stack pointer at function entry); variables of the frame
+		// are at negative offsets, and arguments at positive ones.
+		for _, slot := range f.FrameSlots() {
+			if slot.Offset < 0 {
+				continue
+			}
+			offset := slot.Offset - int32(f.Frame.Size)
+			if _, err := fmt.Fprintf(w, "define_local_var(0x%08X, 0x%08X, \"[bp%+#x]\", %q)\n", f.Addr, f.Addr+funcSize(f), offset, frameSlotName(slot)); err != nil {
+				return errors.WithStack(err)
+			}
+		}
 	}
 	// Create scripts adding global variable types to identifiers.
 	varsPath := filepath.Join(dir, idaVarsName)
@@ -297,6 +444,1017 @@ func dumpIDAOverlay(overlay *csym.Overlay, outputDir string) error {
 			return errors.WithStack(err)
 		}
 	}
+	for _, v := range overlay.Statics {
+		if _, err := fmt.Fprintf(w, "del_items(0x%08X)\n", v.Addr); err != nil {
+			return errors.WithStack(err)
+		}
+		if _, err := fmt.Fprintf(w, "SetType(0x%08X, %q)\n", v.Addr, staticDecl(v)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// idaFuncType returns the IDA type of the given function; a __usercall type
+// with explicit locations if its parameters are not stored according to the
+// MIPS o32 calling convention, and the C function type otherwise.
+func idaFuncType(f *c.FuncDecl) string {
+	if f.IsStdCall() {
+		return f.Var.String()
+	}
+	// In IDA declarations of the __usercall calling convention, the location of
+	// each parameter and of the return value follows its name, in the form
+	// name@<reg> (e.g. "int __usercall f@<$v0>(int a@<$a1>, int b)");
+	// parameters without location are passed on the stack. The declaration is
+	// built from a copy of the function type, with locations appended to the
+	// parameter names and to the function name.
+	t := f.Type.(*c.FuncType)
+	ut := &c.FuncType{
+		RetType:  t.RetType,
+		Variadic: t.Variadic,
+	}
+	for _, param := range t.Params {
+		p := *param
+		if p.Class == c.Register {
+			// Stack arguments are given without location.
+			p.Name = fmt.Sprintf("%s@<%s>", p.Name, c.RegName(p.Addr))
+		}
+		ut.Params = append(ut.Params, &p)
+	}
+	name := "__usercall " + f.Name
+	if size := c.Sizeof(t.RetType); size > 0 && size <= 4 {
+		name = fmt.Sprintf("%s@<%s>", name, c.RegName(c.RegV0))
+	}
+	v := c.Var{Name: name, Type: ut}
+	return v.String()
+}
+
+// --- [ Binary Ninja script ] -------------------------------------------------
+
+// Binary Ninja script name.
+const binjaScriptName = "binja_psx.py"
+
+// binjaHeader is the start of Binary Ninja scripts, defining helper functions
+// used by the rest of the script.
+const binjaHeader = `# Binary Ninja script importing PSX symbol information; generated by sym_dump.
+# Run with bv set to the binary view, e.g. from the Python console.
+
+from binaryninja import Symbol, SymbolType
+
+def func(addr, name, decl):
+    if bv.get_function_at(addr) is None:
+        bv.create_user_function(addr)
+    bv.define_user_symbol(Symbol(SymbolType.FunctionSymbol, addr, name))
+    t, _ = bv.parse_type_string(decl)
+    bv.get_function_at(addr).set_user_type(t)
+
+def label(addr, name):
+    bv.define_user_symbol(Symbol(SymbolType.LocalLabelSymbol, addr, name))
+
+def data(addr, name, decl):
+    t, _ = bv.parse_type_string(decl)
+    bv.define_user_data_var(addr, t)
+    bv.define_user_symbol(Symbol(SymbolType.DataSymbol, addr, name))
+`
+
+// dumpBinjaScripts outputs the types and declarations recorded by the parsers
+// to Binary Ninja scripts stored in the output directory; one for the default
+// binary and one for each overlay. The declarations of decls use the types of
+// types.
+func dumpBinjaScripts(types, decls *csym.Parser, outputDir string) error {
+	typesSrc := &strings.Builder{}
+	if err := writeTypes(typesSrc, types, false); err != nil {
+		return errors.WithStack(err)
+	}
+	dumpOverlay := func(overlay *csym.Overlay, dir string) error {
+		buf := &strings.Builder{}
+		buf.WriteString(binjaHeader)
+		// Define types.
+		fmt.Fprintf(buf, "\ntypes = bv.parse_types_from_string(r\"\"\"\n%s\"\"\")\n", typesSrc)
+		buf.WriteString("for name, t in types.types.items():\n")
+		buf.WriteString("    bv.define_user_type(name, t)\n\n")
+		// Name functions and global variables, and apply their types.
+		for _, f := range overlay.Funcs {
+			fmt.Fprintf(buf, "func(0x%08X, %q, %q)\n", f.Addr, f.Name, f.Var)
+		}
+		for _, v := range overlay.Vars {
+			fmt.Fprintf(buf, "data(0x%08X, %q, %q)\n", v.Addr, v.Name, v.Var)
+		}
+		for _, v := range overlay.Statics {
+			fmt.Fprintf(buf, "data(0x%08X, %q, %q)\n", v.Addr, v.Name, typeDecl(v))
+		}
+		for _, label := range overlay.Labels {
+			fmt.Fprintf(buf, "label(0x%08X, %q)\n", label.Addr, label.Name)
+		}
+		for _, symbol := range overlay.UntypedAddrs() {
+			if symbol.Kind == csym.SymbolCode {
+				fmt.Fprintf(buf, "bv.define_user_symbol(Symbol(SymbolType.FunctionSymbol, 0x%08X, %q))\n", symbol.Addr, symbol.Name)
+			} else {
+				fmt.Fprintf(buf, "bv.define_user_symbol(Symbol(SymbolType.DataSymbol, 0x%08X, %q))\n", symbol.Addr, symbol.Name)
+			}
+		}
+		// Store script.
+		scriptPath := filepath.Join(dir, binjaScriptName)
+		fmt.Println("creating:", scriptPath)
+		if err := ioutil.WriteFile(scriptPath, []byte(buf.String()), 0644); err != nil {
+			return errors.Wrapf(err, "unable to create Binary Ninja script %q", scriptPath)
+		}
+		return nil
+	}
+	return dumpOverlays(decls, outputDir, dumpOverlay)
+}
+
+// --- [ r2 script ] ------------------------------------------------------------
+
+// r2 script name.
+const r2ScriptName = "r2_psx.r2"
+
+// dumpR2Scripts outputs the types and declarations recorded by the parsers to
+// r2/rizin command scripts stored in the output directory; one for the default
+// binary and one for each overlay. The declarations of decls use the types of
+// types.
+func dumpR2Scripts(types, decls *csym.Parser, outputDir string) error {
+	// Type definitions, one per line.
+	var defs []string
+	if def, ok := types.Types["bool"]; ok {
+		defs = append(defs, r2Line(def.Def()))
+	}
+	for _, t := range types.OrderTypes().Defs {
+		defs = append(defs, r2Line(t.Def()))
+	}
+	dumpOverlay := func(overlay *csym.Overlay, dir string) error {
+		buf := &strings.Builder{}
+		buf.WriteString("# r2/rizin script importing PSX symbol information; generated by sym_dump.\n")
+		buf.WriteString("# Load with: . r2_psx.r2\n")
+		buf.WriteString("# List the loaded function signatures with: tf\n")
+		// Load types with td. Function prototypes parsed by td are recorded as
+		// function signatures, listed by tf; tf itself only lists and shows
+		// signatures, so it has no use in the script.
+		buf.WriteString("\n# Types.\n")
+		for _, def := range defs {
+			fmt.Fprintf(buf, "td \"%s;\"\n", def)
+		}
+		for _, f := range overlay.Funcs {
+			fmt.Fprintf(buf, "td \"%s;\"\n", r2Line(f.Var.String()))
+		}
+		// Name functions and global variables, and apply their types.
+		buf.WriteString("\n# Functions.\n")
+		for _, f := range overlay.Funcs {
+			fmt.Fprintf(buf, "f %s %d @ 0x%08X\n", f.Name, f.Size, f.Addr)
+			fmt.Fprintf(buf, "af @ 0x%08X\n", f.Addr)
+			fmt.Fprintf(buf, "afn %s @ 0x%08X\n", f.Name, f.Addr)
+			fmt.Fprintf(buf, "afs %s @ 0x%08X\n", r2Line(f.Var.String()), f.Addr)
+		}
+		buf.WriteString("\n# Global variables.\n")
+		vars := append(append([]*c.VarDecl(nil), overlay.Vars...), overlay.Statics...)
+		for _, v := range vars {
+			fmt.Fprintf(buf, "f %s %d @ 0x%08X\n", v.Name, c.Sizeof(v.Type), v.Addr)
+			if name, ok := r2LinkName(v.Type); ok {
+				fmt.Fprintf(buf, "tl %s = 0x%08X\n", name, v.Addr)
+			}
+		}
+		if len(overlay.Labels) > 0 {
+			buf.WriteString("\n# Labels.\n")
+		}
+		for _, label := range overlay.Labels {
+			fmt.Fprintf(buf, "f %s @ 0x%08X\n", label.Name, label.Addr)
+		}
+		// Absolute symbols are kept in a separate flag space.
+		var abs []*csym.Symbol
+		untyped := overlay.Untyped()
+		if len(untyped) > 0 {
+			buf.WriteString("\n# Symbols without type information.\n")
+		}
+		for _, symbol := range untyped {
+			if symbol.Kind == csym.SymbolAbs {
+				abs = append(abs, symbol)
+				continue
+			}
+			fmt.Fprintf(buf, "f %s @ 0x%08X\n", symbol.Name, symbol.Addr)
+		}
+		if len(abs) > 0 {
+			buf.WriteString("fs+abs\n")
+			for _, symbol := range abs {
+				fmt.Fprintf(buf, "f %s @ 0x%08X\n", symbol.Name, symbol.Addr)
+			}
+			buf.WriteString("fs-\n")
+		}
+		// Store script.
+		scriptPath := filepath.Join(dir, r2ScriptName)
+		fmt.Println("creating:", scriptPath)
+		if err := ioutil.WriteFile(scriptPath, []byte(buf.String()), 0644); err != nil {
+			return errors.Wrapf(err, "unable to create r2 script %q", scriptPath)
+		}
+		return nil
+	}
+	return dumpOverlays(decls, outputDir, dumpOverlay)
+}
+
+// r2Line returns the given C source on a single line, without comments, as
+// required by r2 commands.
+func r2Line(src string) string {
+	var parts []string
+	for _, line := range strings.Split(src, "\n") {
+		if pos := strings.Index(line, "//"); pos != -1 {
+			line = line[:pos]
+		}
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// r2LinkName returns the name of the struct or union linked by r2 to variables
+// of the given type, if any.
+func r2LinkName(t c.Type) (string, bool) {
+	switch t := t.(type) {
+	case *c.StructType:
+		return t.Tag, true
+	case *c.UnionType:
+		return t.Tag, !c.IsFakeTag(t.Tag)
+	case *c.TypedefType:
+		return r2LinkName(t.Typedef.Type)
+	}
+	return "", false
+}
+
+// --- [ Ghidra script ] -------------------------------------------------------
+
+// Ghidra script name.
+const ghidraScriptName = "ghidra_psx.py"
+
+// ghidraHeader is the start of Ghidra scripts, defining helper functions used
+// by the rest of the script.
+const ghidraHeader = `# Ghidra script importing PSX symbol information; generated by sym_dump.
+#@category PSX
+
+from ghidra.app.cmd.function import ApplyFunctionSignatureCmd
+from ghidra.program.model.data import *
+from ghidra.program.model.listing import Function, LocalVariableImpl, ParameterImpl
+from ghidra.program.model.symbol import SourceType
+
+dtm = currentProgram.getDataTypeManager()
+category = CategoryPath("/psx")
+types = {}
+funcdefs = [0]
+
+def add_type(key, dt):
+    dt = dtm.addDataType(dt, DataTypeConflictHandler.REPLACE_HANDLER)
+    types[key] = dt
+    return dt
+
+def ptr(dt):
+    return PointerDataType(dt, 4, dtm)
+
+def array(dt, n):
+    return ArrayDataType(dt, n, dt.getLength(), dtm)
+
+def funcdef(name, ret, params, varargs):
+    if name is None:
+        funcdefs[0] += 1
+        name = "_func_%d" % funcdefs[0]
+    f = FunctionDefinitionDataType(category, name, dtm)
+    f.setReturnType(ret)
+    f.setArguments([ParameterDefinitionImpl(n, dt, None) for n, dt in params])
+    f.setVarArgs(varargs)
+    return f
+
+def overlay_space(name, start, length):
+    mem = currentProgram.getMemory()
+    block = mem.getBlock(name)
+    if block is None:
+        block = mem.createUninitializedBlock(name, toAddr(start), length, True)
+    return block.getStart().getAddressSpace()
+
+def label(space, addr, name):
+    createLabel(space.getAddress(addr), name, True, SourceType.IMPORTED)
+
+def func(space, addr, name, sig):
+    a = space.getAddress(addr)
+    if getFunctionAt(a) is None:
+        createFunction(a, name)
+    label(space, addr, name)
+    ApplyFunctionSignatureCmd(a, sig, SourceType.IMPORTED).applyTo(currentProgram)
+
+def custom_storage(space, addr, params):
+    f = getFunctionAt(space.getAddress(addr))
+    if f is None:
+        return
+    vars = []
+    for name, dt, loc in params:
+        if isinstance(loc, str):
+            reg = currentProgram.getRegister(loc)
+            vars.append(ParameterImpl(name, dt, reg, currentProgram, SourceType.IMPORTED))
+        else:
+            vars.append(ParameterImpl(name, dt, loc, currentProgram, SourceType.IMPORTED))
+    try:
+        f.setCustomVariableStorage(True)
+        f.replaceParameters(vars, Function.FunctionUpdateType.CUSTOM_STORAGE, True, SourceType.IMPORTED)
+    except Exception as e:
+        print("unable to set parameter storage of %s: %s" % (f.getName(), e))
+
+def stack_var(space, addr, offset, name, dt):
+    f = getFunctionAt(space.getAddress(addr))
+    if f is None:
+        return
+    v = LocalVariableImpl(name, dt, offset, currentProgram)
+    try:
+        f.addLocalVariable(v, SourceType.IMPORTED)
+    except Exception as e:
+        print("unable to add stack variable %s of %s: %s" % (name, f.getName(), e))
+
+def data(space, addr, name, dt):
+    a = space.getAddress(addr)
+    label(space, addr, name)
+    if dt.getLength() > 0:
+        clearListing(a, a.add(dt.getLength() - 1))
+        createData(a, dt)
+`
+
+// dumpGhidraScript outputs the types and declarations recorded by the parsers
+// to a Ghidra script stored in the output directory. The declarations of decls
+// use the types of types.
+func dumpGhidraScript(types, decls *csym.Parser, outputDir string) error {
+	buf := &strings.Builder{}
+	buf.WriteString(ghidraHeader)
+	// Create types, starting with empty structs and unions so that they may be
+	// referred to by the others.
+	buf.WriteString("\n# Types.\n")
+	if def, ok := types.Types["bool"].(*c.VarDecl); ok {
+		fmt.Fprintf(buf, "add_type(%q, TypedefDataType(category, %q, %s, dtm))\n", def.Name, def.Name, ghidraType(def.Type))
+	}
+	order := types.OrderTypes()
+	for _, t := range order.Defs {
+		switch t := t.(type) {
+		case *c.StructType:
+			fmt.Fprintf(buf, "add_type(%q, StructureDataType(category, %q, 0x%X, dtm))\n", t.String(), t.Tag, t.Size)
+		case *c.UnionType:
+			fmt.Fprintf(buf, "add_type(%q, UnionDataType(category, %q, dtm))\n", t.String(), t.Tag)
+		case *c.EnumType:
+			fmt.Fprintf(buf, "e = EnumDataType(category, %q, 4, dtm)\n", t.Tag)
+			for _, member := range t.Members {
+				fmt.Fprintf(buf, "e.add(%q, %d)\n", member.Name, member.Value)
+			}
+			fmt.Fprintf(buf, "add_type(%q, e)\n", t.String())
+		}
+	}
+	for _, t := range order.Defs {
+		if def, ok := t.(*c.VarDecl); ok {
+			fmt.Fprintf(buf, "add_type(%q, TypedefDataType(category, %q, %s, dtm))\n", def.Name, def.Name, ghidraType(def.Type))
+		}
+	}
+	// Fill structs and unions, after the types they contain by value.
+	filled := make(map[c.Type]bool)
+	for _, t := range order.Defs {
+		ghidraFill(buf, t, filled)
+	}
+	// Label functions and global variables, and apply their types.
+	overlays := append([]*csym.Overlay{decls.Overlay}, decls.Overlays...)
+	for _, overlay := range overlays {
+		if overlay.ID == 0 {
+			buf.WriteString("\n# Default binary.\n")
+			buf.WriteString("space = currentProgram.getAddressFactory().getDefaultAddressSpace()\n")
+		} else {
+			fmt.Fprintf(buf, "\n# Overlay ID %x.\n", overlay.ID)
+			fmt.Fprintf(buf, "space = overlay_space(\"overlay_%x\", 0x%08X, 0x%X)\n", overlay.ID, overlay.Addr, overlay.Length)
+		}
+		for _, f := range overlay.Funcs {
+			fmt.Fprintf(buf, "func(space, 0x%08X, %q, %s)\n", f.Addr, f.Name, ghidraFuncDef(f.Name, f.Type))
+			if !f.IsStdCall() {
+				fmt.Fprintf(buf, "custom_storage(space, 0x%08X, %s)\n", f.Addr, ghidraParams(f))
+			}
+			// Stack offsets are relative to the stack pointer on entry.
+			for _, slot := range f.FrameSlots() {
+				if slot.Arg {
+					continue
+				}
+				dt := "UnsignedIntegerDataType.dataType"
+				if slot.Type != nil {
+					dt = ghidraType(slot.Type)
+				}
+				fmt.Fprintf(buf, "stack_var(space, 0x%08X, %d, %q, %s)\n", f.Addr, slot.Offset-int32(f.Frame.Size), frameSlotName(slot), dt)
+			}
+		}
+		vars := append(append([]*c.VarDecl(nil), overlay.Vars...), overlay.Statics...)
+		for _, v := range vars {
+			fmt.Fprintf(buf, "data(space, 0x%08X, %q, %s)\n", v.Addr, v.Name, ghidraType(v.Type))
+		}
+		for _, label := range overlay.Labels {
+			fmt.Fprintf(buf, "label(space, 0x%08X, %q)\n", label.Addr, label.Name)
+		}
+		for _, symbol := range overlay.UntypedAddrs() {
+			fmt.Fprintf(buf, "label(space, 0x%08X, %q)\n", symbol.Addr, symbol.Name)
+		}
+	}
+	// Store script.
+	scriptPath := filepath.Join(outputDir, ghidraScriptName)
+	fmt.Println("creating:", scriptPath)
+	if err := ioutil.WriteFile(scriptPath, []byte(buf.String()), 0644); err != nil {
+		return errors.Wrapf(err, "unable to create Ghidra script %q", scriptPath)
+	}
+	return nil
+}
+
+// ghidraFill outputs the fields of the given struct or union to the Ghidra
+// script, once the structs and unions it contains by value have been filled.
+func ghidraFill(buf *strings.Builder, t c.Type, filled map[c.Type]bool) {
+	var fields []c.Field
+	switch t := t.(type) {
+	case *c.StructType:
+		fields = t.Fields
+	case *c.UnionType:
+		fields = t.Fields
+	default:
+		return
+	}
+	if filled[t] {
+		return
+	}
+	filled[t] = true
+	for _, field := range fields {
+		ghidraFill(buf, valueType(field.Type), filled)
+	}
+	switch t := t.(type) {
+	case *c.StructType:
+		fmt.Fprintf(buf, "s = types[%q]\n", t.String())
+		for _, field := range t.Fields {
+			if field.BitWidth > 0 {
+				// Place bitfields in the fewest bytes covering them.
+				offset := field.BitOffset / 8
+				bit := field.BitOffset % 8
+				width := (bit + field.BitWidth + 7) / 8
+				fmt.Fprintf(buf, "s.insertBitFieldAt(0x%X, %d, %d, %s, %d, %q, None)\n", offset, width, bit, ghidraType(field.Type), field.BitWidth, field.Name)
+				continue
+			}
+			fmt.Fprintf(buf, "dt = %s\n", ghidraType(field.Type))
+			fmt.Fprintf(buf, "s.replaceAtOffset(0x%X, dt, dt.getLength(), %q, None)\n", field.Offset, field.Name)
+		}
+	case *c.UnionType:
+		fmt.Fprintf(buf, "u = types[%q]\n", t.String())
+		for _, field := range t.Fields {
+			if field.BitWidth > 0 {
+				fmt.Fprintf(buf, "u.addBitField(%s, %d, %q, None)\n", ghidraType(field.Type), field.BitWidth, field.Name)
+				continue
+			}
+			fmt.Fprintf(buf, "dt = %s\n", ghidraType(field.Type))
+			fmt.Fprintf(buf, "u.add(dt, dt.getLength(), %q, None)\n", field.Name)
+		}
+	}
+}
+
+// valueType returns the struct or union contained by value in the given type,
+// looking through arrays and typedefs.
+func valueType(t c.Type) c.Type {
+	switch t := t.(type) {
+	case *c.ArrayType:
+		return valueType(t.Elem)
+	case *c.TypedefType:
+		return valueType(t.Typedef.Type)
+	}
+	return t
+}
+
+// ghidraType returns a Python expression evaluating to the Ghidra data type of
+// the given type.
+func ghidraType(t c.Type) string {
+	switch t := t.(type) {
+	case c.BaseType:
+		switch t {
+		case c.Void:
+			return "VoidDataType.dataType"
+		case c.Char:
+			return "CharDataType.dataType"
+		case c.Short:
+			return "ShortDataType.dataType"
+		case c.Int:
+			return "IntegerDataType.dataType"
+		case c.Long:
+			return "LongDataType.dataType"
+		case c.UChar:
+			return "UnsignedCharDataType.dataType"
+		case c.UShort:
+			return "UnsignedShortDataType.dataType"
+		case c.UInt:
+			return "UnsignedIntegerDataType.dataType"
+		case c.ULong:
+			return "UnsignedLongDataType.dataType"
+		case c.Float:
+			return "FloatDataType.dataType"
+		case c.Double:
+			return "DoubleDataType.dataType"
+		}
+	case *c.StructType, *c.UnionType, *c.EnumType:
+		return fmt.Sprintf("types[%q]", t)
+	case *c.TypedefType:
+		return fmt.Sprintf("types[%q]", t.Typedef.Name)
+	case *c.PointerType:
+		return fmt.Sprintf("ptr(%s)", ghidraType(t.Elem))
+	case *c.ArrayType:
+		return fmt.Sprintf("array(%s, %d)", ghidraType(t.Elem), t.Len)
+	case *c.FuncType:
+		return ghidraFuncDef("", t)
+	}
+	panic(fmt.Errorf("support for type %T not yet implemented", t))
+}
+
+// ghidraFuncDef returns a Python expression evaluating to the Ghidra function
+// definition of the given function type; an anonymous one if name is empty.
+func ghidraFuncDef(name string, t c.Type) string {
+	ft, ok := t.(*c.FuncType)
+	if !ok {
+		panic(fmt.Errorf("invalid function type %T", t))
+	}
+	var params []string
+	for _, param := range ft.Params {
+		params = append(params, fmt.Sprintf("(%q, %s)", param.Name, ghidraType(param.Type)))
+	}
+	pyName := "None"
+	if len(name) > 0 {
+		pyName = strconv.Quote(name)
+	}
+	varargs := "False"
+	if ft.Variadic {
+		varargs = "True"
+	}
+	return fmt.Sprintf("funcdef(%s, %s, [%s], %s)", pyName, ghidraType(ft.RetType), strings.Join(params, ", "), varargs)
+}
+
+// ghidraParams returns a Python list of the name, data type and location of
+// each parameter of the given function; the location is either a register name
+// or a stack offset relative to the stack pointer on entry.
+func ghidraParams(f *c.FuncDecl) string {
+	t := f.Type.(*c.FuncType)
+	var params []string
+	for _, param := range t.Params {
+		var loc string
+		switch param.Class {
+		case c.Register:
+			loc = strconv.Quote(strings.TrimPrefix(c.RegName(param.Addr), "$"))
+		default:
+			offset := int32(param.Addr)
+			if f.Frame != nil {
+				offset -= int32(f.Frame.Size)
+			}
+			loc = strconv.Itoa(int(offset))
+		}
+		params = append(params, fmt.Sprintf("(%q, %s, %s)", param.Name, ghidraType(param.Type), loc))
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// --- [ splat configuration ] -------------------------------------------------
+
+const (
+	// splat symbol addresses file name.
+	splatSymsName = "symbol_addrs.txt"
+	// splat configuration file name.
+	splatConfigName = "splat.yaml"
+	// Size of the PS-X EXE header preceding the code of the default binary.
+	exeHeaderSize = 0x800
+)
+
+// dumpSplat outputs the declarations recorded by the parser to splat symbol
+// address files and configurations stored in the output directory; one for the
+// default binary and one for each overlay. The load address of the default
+// binary is taken from the PS-X EXE at exePath, if given.
+func dumpSplat(p *csym.Parser, outputDir, exePath string) error {
+	var exe *psxExe
+	if len(exePath) > 0 {
+		var err error
+		if exe, err = parseExe(exePath); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	dumpOverlay := func(overlay *csym.Overlay, dir string) error {
+		if overlay.ID != 0 {
+			return dumpSplatOverlay(overlay, dir, nil)
+		}
+		return dumpSplatOverlay(overlay, dir, exe)
+	}
+	return dumpOverlays(p, outputDir, dumpOverlay)
+}
+
+// dumpSplatOverlay outputs the declarations of the overlay to a splat symbol
+// addresses file and configuration stored in the given directory. The code of
+// the default binary is described by exe, if non-nil.
+func dumpSplatOverlay(overlay *csym.Overlay, dir string, exe *psxExe) error {
+	funcs := append([]*c.FuncDecl(nil), overlay.Funcs...)
+	sort.SliceStable(funcs, func(i, j int) bool {
+		return funcs[i].Addr < funcs[j].Addr
+	})
+	vars := append(append([]*c.VarDecl(nil), overlay.Vars...), overlay.Statics...)
+	sort.SliceStable(vars, func(i, j int) bool {
+		return vars[i].Addr < vars[j].Addr
+	})
+	// Create symbol addresses file.
+	buf := &strings.Builder{}
+	for _, f := range funcs {
+		fmt.Fprintf(buf, "%s = 0x%08X; // type:func", f.Name, f.Addr)
+		if size := funcSize(f); size > 0 {
+			fmt.Fprintf(buf, " size:0x%X", size)
+		}
+		buf.WriteString("\n")
+	}
+	for _, v := range vars {
+		fmt.Fprintf(buf, "%s = 0x%08X; // type:data", v.Name, v.Addr)
+		if size := varSize(v); size > 0 {
+			fmt.Fprintf(buf, " size:0x%X", size)
+		}
+		buf.WriteString("\n")
+	}
+	for _, label := range overlay.Labels {
+		fmt.Fprintf(buf, "%s = 0x%08X; // type:label\n", label.Name, label.Addr)
+	}
+	for _, symbol := range overlay.UntypedAddrs() {
+		if symbol.Kind == csym.SymbolCode {
+			fmt.Fprintf(buf, "%s = 0x%08X; // type:func\n", symbol.Name, symbol.Addr)
+		} else {
+			fmt.Fprintf(buf, "%s = 0x%08X; // type:data\n", symbol.Name, symbol.Addr)
+		}
+	}
+	symsPath := filepath.Join(dir, splatSymsName)
+	fmt.Println("creating:", symsPath)
+	if err := ioutil.WriteFile(symsPath, []byte(buf.String()), 0644); err != nil {
+		return errors.Wrapf(err, "unable to create splat symbol addresses %q", symsPath)
+	}
+	// Create configuration, with the code split into one subsegment per source
+	// file. The load address of the default binary is taken from the t_addr
+	// field of its PS-X EXE header. Without the executable, it is guessed to be
+	// the address of the first function, which is wrong if code without debug
+	// information (e.g. crt0 or library code) precedes it.
+	name := "main"
+	target := "main.exe"
+	start := uint32(exeHeaderSize)
+	vram := overlay.Addr
+	switch {
+	case overlay.ID != 0:
+		name = fmt.Sprintf("overlay_%x", overlay.ID)
+		target = name + ".bin"
+		start = 0
+	case exe != nil:
+		vram = exe.Addr
+	case len(funcs) > 0:
+		vram = funcs[0].Addr
+	}
+	buf = &strings.Builder{}
+	buf.WriteString("# splat configuration generated by sym_dump; set target_path to the binary.\n")
+	fmt.Fprintf(buf, "name: %s\n", name)
+	buf.WriteString("options:\n")
+	buf.WriteString("  platform: psx\n")
+	fmt.Fprintf(buf, "  basename: %s\n", name)
+	buf.WriteString("  base_path: .\n")
+	fmt.Fprintf(buf, "  target_path: %s\n", target)
+	buf.WriteString("  asm_path: asm\n")
+	buf.WriteString("  src_path: src\n")
+	buf.WriteString("  build_path: build\n")
+	fmt.Fprintf(buf, "  symbol_addrs_path: %s\n", splatSymsName)
+	buf.WriteString("  compiler: PSYQ\n")
+	buf.WriteString("segments:\n")
+	if overlay.ID == 0 {
+		buf.WriteString("  - name: header\n")
+		buf.WriteString("    type: header\n")
+		buf.WriteString("    start: 0x0\n")
+	}
+	fmt.Fprintf(buf, "  - name: %s\n", name)
+	buf.WriteString("    type: code\n")
+	fmt.Fprintf(buf, "    start: 0x%X\n", start)
+	fmt.Fprintf(buf, "    vram: 0x%08X\n", vram)
+	buf.WriteString("    subsegments:\n")
+	used := make(map[string]bool)
+	end := start
+	for i, f := range funcs {
+		if f.Addr < vram || (i > 0 && f.Path == funcs[i-1].Path) {
+			continue
+		}
+		segType, segName := splatSubsegment(f)
+		base := segName
+		for j := 2; used[segName]; j++ {
+			segName = fmt.Sprintf("%s_%d", base, j)
+		}
+		used[segName] = true
+		offset := start + f.Addr - vram
+		if len(f.Path) > 0 {
+			fmt.Fprintf(buf, "      - [0x%X, %s, %s] # %s\n", offset, segType, segName, f.Path)
+		} else {
+			fmt.Fprintf(buf, "      - [0x%X, %s, %s]\n", offset, segType, segName)
+		}
+	}
+	// The code ends at the highest code address, including code without type
+	// information (e.g. library functions); code symbols of unknown size span
+	// at least one instruction.
+	code, _, _ := overlayNames(overlay)
+	for _, name := range code {
+		if name.Addr < vram {
+			continue
+		}
+		size := name.Size
+		if size == 0 {
+			size = 4
+		}
+		if e := start + name.Addr - vram + size; e > end {
+			end = e
+		}
+	}
+	if overlay.ID != 0 && overlay.Length > end {
+		end = overlay.Length
+	}
+	if exe != nil && start+uint32(len(exe.Data)) > end {
+		end = start + uint32(len(exe.Data))
+	}
+	fmt.Fprintf(buf, "  - [0x%X]\n", end)
+	configPath := filepath.Join(dir, splatConfigName)
+	fmt.Println("creating:", configPath)
+	if err := ioutil.WriteFile(configPath, []byte(buf.String()), 0644); err != nil {
+		return errors.Wrapf(err, "unable to create splat configuration %q", configPath)
+	}
+	return nil
+}
+
+// funcSize returns the size in bytes of the given function. Note, the frame
+// size of function start symbols is not the size of the function; the size is
+// taken from the function definition, or else from the function end symbol.
+func funcSize(f *c.FuncDecl) uint32 {
+	if f.Size > 0 {
+		return f.Size
+	}
+	if f.AddrEnd > f.Addr {
+		return f.AddrEnd - f.Addr
+	}
+	return 0
+}
+
+// varSize returns the size in bytes of the given variable.
+func varSize(v *c.VarDecl) uint32 {
+	if v.Size > 0 {
+		return v.Size
+	}
+	return c.Sizeof(v.Type)
+}
+
+// typeDecl returns the type of the given variable in C syntax, without the
+// variable name; for use with names not valid in C (e.g. func.var).
+func typeDecl(v *c.VarDecl) string {
+	t := c.Var{Type: v.Type}
+	return strings.TrimSpace(t.String())
+}
+
+// staticDecl returns the declaration of the given static local variable in C
+// syntax. The variable is declared by its unqualified name (e.g. counter of
+// main.counter), as qualified names are not valid in C.
+func staticDecl(v *c.VarDecl) string {
+	name := v.Name
+	if pos := strings.LastIndex(name, "."); pos != -1 {
+		name = name[pos+1:]
+	}
+	if !isIdent(name) {
+		return typeDecl(v)
+	}
+	return c.Var{Type: v.Type, Name: name}.String()
+}
+
+// frameSlotName returns the name of the given stack frame slot; saved
+// registers are named after the register (e.g. saved_ra).
+func frameSlotName(slot c.FrameSlot) string {
+	if slot.Type == nil {
+		return "saved_" + strings.TrimPrefix(slot.Name, "$")
+	}
+	return slot.Name
+}
+
+// splatSubsegment returns the splat subsegment type and name of the source
+// file containing the given function.
+func splatSubsegment(f *c.FuncDecl) (segType, name string) {
+	if len(f.Path) == 0 {
+		return "asm", f.Name
+	}
+	// Source paths use Windows path separators.
+	base := f.Path
+	if pos := strings.LastIndexAny(base, `\/`); pos != -1 {
+		base = base[pos+1:]
+	}
+	ext := filepath.Ext(base)
+	name = strings.ToLower(strings.TrimSuffix(base, ext))
+	if strings.EqualFold(ext, ".c") {
+		return "c", name
+	}
+	return "asm", name
+}
+
+// --- [ Emulator symbols ] ----------------------------------------------------
+
+// Symbol file name formats, taking the file extension; of the default binary
+// and of overlays.
+const (
+	symbolsNameFormat        = "symbols.%s"
+	overlaySymbolsNameFormat = "overlay_%x.%s"
+)
+
+// dumpNocash outputs the symbols recorded by the parser to no$psx symbol files
+// stored in the output directory; one for the default binary and one for each
+// overlay. The format is also understood by DuckStation.
+func dumpNocash(p *csym.Parser, outputDir string) error {
+	return dumpSymbolFiles(p, outputDir, "sym", writeNocash)
+}
+
+// dumpMap outputs the symbols recorded by the parser to GNU ld map files, as
+// loaded by PCSX-Redux, stored in the output directory; one for the default
+// binary and one for each overlay.
+func dumpMap(p *csym.Parser, outputDir string) error {
+	return dumpSymbolFiles(p, outputDir, "map", writeMap)
+}
+
+// dumpSymbolFiles outputs the symbols of the default binary and of each overlay
+// of the parser to files with the given extension stored in the output
+// directory, using write.
+func dumpSymbolFiles(p *csym.Parser, outputDir, ext string, write func(w io.Writer, overlay *csym.Overlay) error) error {
+	overlays := append([]*csym.Overlay{p.Overlay}, p.Overlays...)
+	for _, overlay := range overlays {
+		name := fmt.Sprintf(symbolsNameFormat, ext)
+		if overlay.ID != 0 {
+			name = fmt.Sprintf(overlaySymbolsNameFormat, overlay.ID, ext)
+		}
+		path := filepath.Join(outputDir, name)
+		fmt.Println("creating:", path)
+		if err := writeSymbolFile(path, overlay, write); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// writeSymbolFile writes the symbols of the overlay to the given file, using
+// write.
+func writeSymbolFile(path string, overlay *csym.Overlay, write func(w io.Writer, overlay *csym.Overlay) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create symbol file %q", path)
+	}
+	if err := write(f, overlay); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "unable to close symbol file %q", path)
+	}
+	return nil
+}
+
+// writeNocash writes the symbols of the overlay to w in no$psx format, as lines
+// of address and name pairs.
+func writeNocash(w io.Writer, overlay *csym.Overlay) error {
+	// Only addresses of code and data are listed, and not of local symbols, as
+	// their names need not be unique.
+	code, data, _ := overlayNames(overlay)
+	names := append(code, data...)
+	sort.SliceStable(names, func(i, j int) bool {
+		return names[i].Addr < names[j].Addr
+	})
+	for _, name := range names {
+		if name.Local {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%08X %s\n", name.Addr, name.Name); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// writeMap writes the symbols of the overlay to w in GNU ld map format, with
+// absolute symbols first, followed by code in the .text section and data in the
+// .data section.
+func writeMap(w io.Writer, overlay *csym.Overlay) error {
+	if _, err := fmt.Fprintf(w, "Linker script and memory map\n\n"); err != nil {
+		return errors.WithStack(err)
+	}
+	code, data, abs := overlayNames(overlay)
+	for _, name := range abs {
+		if _, err := fmt.Fprintf(w, "                0x%08x                %s = 0x%x\n", name.Addr, name.Name, name.Addr); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(abs) > 0 {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	sections := []struct {
+		name  string
+		names []addrName
+	}{
+		{name: ".text", names: code},
+		{name: ".data", names: data},
+	}
+	for _, section := range sections {
+		if len(section.names) == 0 {
+			continue
+		}
+		start := section.names[0].Addr
+		end := start
+		for _, name := range section.names {
+			if e := name.Addr + name.Size; e > end {
+				end = e
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%-15s0x%08x %10s\n", section.name, start, fmt.Sprintf("0x%x", end-start)); err != nil {
+			return errors.WithStack(err)
+		}
+		for _, name := range section.names {
+			if _, err := fmt.Fprintf(w, "                0x%08x                %s\n", name.Addr, name.Name); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// An addrName associates a name with an address.
+type addrName struct {
+	// Address.
+	Addr uint32
+	// Size in bytes; zero if unknown.
+	Size uint32
+	// Name.
+	Name string
+	// Local symbol.
+	Local bool
+}
+
+// overlayNames returns the named addresses of code and data of the overlay, and
+// the absolute symbols, sorted by address. Functions and labels are code, and
+// variables are data; symbols without type information are classified by their
+// kind. Names of local symbols are marked as such.
+func overlayNames(overlay *csym.Overlay) (code, data, abs []addrName) {
+	seen := make(map[addrName]bool)
+	add := func(names *[]addrName, addr, size uint32, name string, local bool) {
+		key := addrName{Addr: addr, Name: name}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		*names = append(*names, addrName{Addr: addr, Size: size, Name: name, Local: local})
+	}
+	// Declarations named by local symbols are local.
+	locals := make(map[string]bool)
+	for _, symbol := range overlay.Symbols {
+		if symbol.Binding == csym.BindLocal {
+			locals[symbol.Name] = true
+		}
+	}
+	for _, f := range overlay.Funcs {
+		add(&code, f.Addr, funcSize(f), f.Name, locals[f.Name])
+	}
+	for _, label := range overlay.Labels {
+		add(&code, label.Addr, 0, label.Name, false)
+	}
+	for _, v := range overlay.Vars {
+		add(&data, v.Addr, varSize(v), v.Name, locals[v.Name])
+	}
+	for _, v := range overlay.Statics {
+		add(&data, v.Addr, varSize(v), v.Name, false)
+	}
+	for _, symbol := range overlay.Untyped() {
+		local := symbol.Binding == csym.BindLocal
+		switch symbol.Kind {
+		case csym.SymbolCode:
+			add(&code, symbol.Addr, 0, symbol.Name, local)
+		case csym.SymbolAbs:
+			add(&abs, symbol.Addr, 0, symbol.Name, false)
+		default:
+			add(&data, symbol.Addr, 0, symbol.Name, local)
+		}
+	}
+	for _, names := range [][]addrName{code, data, abs} {
+		names := names
+		sort.SliceStable(names, func(i, j int) bool {
+			return names[i].Addr < names[j].Addr
+		})
+	}
+	return code, data, abs
+}
+
+// --- [ JSON model ] ----------------------------------------------------------
+
+// JSON model file name.
+const modelName = "model.json"
+
+// dumpModel outputs the types and declarations recorded by the parser in JSON
+// format to a file stored in the output directory.
+func dumpModel(p *csym.Parser, outputDir string) error {
+	modelPath := filepath.Join(outputDir, modelName)
+	fmt.Println("creating:", modelPath)
+	f, err := os.Create(modelPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create JSON model %q", modelPath)
+	}
+	defer f.Close()
+	if err := p.WriteJSON(f); err != nil {
+		return errors.WithStack(err)
+	}
 	return nil
 }
 
@@ -4,14 +4,31 @@ package c
 
 import "strconv"
 
-const _BaseType_name = "voidcharshortintlongunsigned charunsigned shortunsigned intunsigned long"
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[Void-1]
+	_ = x[Char-2]
+	_ = x[Short-3]
+	_ = x[Int-4]
+	_ = x[Long-5]
+	_ = x[UChar-6]
+	_ = x[UShort-7]
+	_ = x[UInt-8]
+	_ = x[ULong-9]
+	_ = x[Float-10]
+	_ = x[Double-11]
+}
+
+const _BaseType_name = "voidcharshortintlongunsigned charunsigned shortunsigned intunsigned longfloatdouble"
 
-var _BaseType_index = [...]uint8{0, 4, 8, 13, 16, 20, 33, 47, 59, 72}
+var _BaseType_index = [...]uint8{0, 4, 8, 13, 16, 20, 33, 47, 59, 72, 77, 83}
 
 func (i BaseType) String() string {
-	i -= 1
-	if i >= BaseType(len(_BaseType_index)-1) {
-		return "BaseType(" + strconv.FormatInt(int64(i+1), 10) + ")"
+	idx := int(i) - 1
+	if i < 1 || idx >= len(_BaseType_index)-1 {
+		return "BaseType(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _BaseType_name[_BaseType_index[i]:_BaseType_index[i+1]]
+	return _BaseType_name[_BaseType_index[idx]:_BaseType_index[idx+1]]
 }
@@ -30,7 +30,9 @@ func (v *VarDecl) Def() string {
 	buf := &strings.Builder{}
 	switch v.Class {
 	case Register:
-		fmt.Fprintf(buf, "// register: %d\n", v.Addr)
+		fmt.Fprintf(buf, "// register: %s\n", RegName(v.Addr))
+	case Auto:
+		fmt.Fprintf(buf, "// stack offset: %+#x\n", int32(v.Addr))
 	default:
 		if v.Addr > 0 {
 			fmt.Fprintf(buf, "// address: 0x%08X\n", v.Addr)
@@ -67,6 +69,8 @@ type FuncDecl struct {
 	Path string
 	// Address (optional).
 	Addr uint32
+	// End address (optional).
+	AddrEnd uint32
 	// Size (optional).
 	Size uint32
 	// Start line number.
@@ -75,6 +79,8 @@ type FuncDecl struct {
 	LineEnd uint32
 	// Underlying function variable.
 	Var
+	// Stack frame (optional).
+	Frame *Frame
 	// Scope blocks.
 	Blocks []*Block
 }
@@ -97,6 +103,8 @@ func (f *FuncDecl) Def() string {
 	}
 	fmt.Fprintf(buf, "// line start: %d\n", f.LineStart)
 	fmt.Fprintf(buf, "// line end:   %d\n", f.LineEnd)
+	buf.WriteString(f.frameDef())
+	buf.WriteString(f.paramsDef())
 	if len(f.Blocks) == 0 {
 		fmt.Fprintf(buf, "%s;", f.Var)
 		return buf.String()
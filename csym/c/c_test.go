@@ -0,0 +1,212 @@
+package c_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mefistotelis/psx_mnd_sym/csym/c"
+)
+
+func TestSizeofAlignof(t *testing.T) {
+	golden := []struct {
+		name  string
+		t     c.Type
+		size  uint32
+		align uint32
+	}{
+		{name: "char", t: c.Char, size: 1, align: 1},
+		{name: "short", t: c.UShort, size: 2, align: 2},
+		{name: "double", t: c.Double, size: 8, align: 8},
+		{name: "pointer", t: &c.PointerType{Elem: c.Char}, size: 4, align: 4},
+		{name: "array", t: &c.ArrayType{Elem: c.Short, Len: 3}, size: 6, align: 2},
+		// struct { char a; int b; }
+		{
+			name: "struct",
+			t: &c.StructType{Tag: "s", Fields: []c.Field{
+				{Offset: 0, Var: c.Var{Type: c.Char, Name: "a"}},
+				{Offset: 4, Var: c.Var{Type: c.Int, Name: "b"}},
+			}},
+			size:  8,
+			align: 4,
+		},
+		// struct { short a; char b; }; tail padding to the alignment.
+		{
+			name: "struct tail",
+			t: &c.StructType{Tag: "s", Fields: []c.Field{
+				{Offset: 0, Var: c.Var{Type: c.Short, Name: "a"}},
+				{Offset: 2, Var: c.Var{Type: c.Char, Name: "b"}},
+			}},
+			size:  4,
+			align: 2,
+		},
+		// Size of the SYM file.
+		{
+			name: "struct size",
+			t: &c.StructType{Size: 12, Tag: "s", Fields: []c.Field{
+				{Offset: 0, Var: c.Var{Type: c.Char, Name: "a"}},
+			}},
+			size:  12,
+			align: 1,
+		},
+		// union { char a; double b; int c[3]; }
+		{
+			name: "union",
+			t: &c.UnionType{Tag: "u", Fields: []c.Field{
+				{Var: c.Var{Type: c.Char, Name: "a"}},
+				{Var: c.Var{Type: c.Double, Name: "b"}},
+				{Var: c.Var{Type: &c.ArrayType{Elem: c.Int, Len: 3}, Name: "c"}},
+			}},
+			size:  16,
+			align: 8,
+		},
+		// struct { unsigned int a : 3; unsigned int b : 5; }
+		{
+			name: "bitfields",
+			t: &c.StructType{Tag: "s", Fields: []c.Field{
+				{BitOffset: 0, BitWidth: 3, Var: c.Var{Type: c.UInt, Name: "a"}},
+				{BitOffset: 3, BitWidth: 5, Var: c.Var{Type: c.UInt, Name: "b"}},
+			}},
+			size:  4,
+			align: 4,
+		},
+	}
+	for _, g := range golden {
+		if got := c.Sizeof(g.t); got != g.size {
+			t.Errorf("%s: size mismatch; expected %d, got %d", g.name, g.size, got)
+		}
+		if got := c.Alignof(g.t); got != g.align {
+			t.Errorf("%s: alignment mismatch; expected %d, got %d", g.name, g.align, got)
+		}
+	}
+}
+
+func TestStructPadding(t *testing.T) {
+	golden := []struct {
+		name string
+		t    *c.StructType
+		// Padding members expected in the definition.
+		want []string
+	}{
+		// Gap before a field, beyond its alignment, and tail padding up to the
+		// size of the SYM file.
+		{
+			name: "field and tail",
+			t: &c.StructType{Size: 16, Tag: "s", Fields: []c.Field{
+				{Offset: 0, Size: 1, Var: c.Var{Type: c.Char, Name: "a"}},
+				{Offset: 8, Size: 4, Var: c.Var{Type: c.Int, Name: "b"}},
+			}},
+			want: []string{"char pad_0001[7];", "char pad_000C[4];"},
+		},
+		// No padding where the alignment of fields accounts for the gap.
+		{
+			name: "aligned",
+			t: &c.StructType{Size: 8, Tag: "s", Fields: []c.Field{
+				{Offset: 0, Size: 1, Var: c.Var{Type: c.Char, Name: "a"}},
+				{Offset: 4, Size: 4, Var: c.Var{Type: c.Int, Name: "b"}},
+			}},
+		},
+		// Unnamed bitfield between bitfields.
+		{
+			name: "bitfield bits",
+			t: &c.StructType{Size: 4, Tag: "s", Fields: []c.Field{
+				{BitOffset: 0, BitWidth: 3, Var: c.Var{Type: c.UInt, Name: "a"}},
+				{BitOffset: 8, BitWidth: 4, Var: c.Var{Type: c.UInt, Name: "b"}},
+			}},
+			want: []string{"unsigned int : 5;"},
+		},
+		// Byte padding between byte aligned bitfields.
+		{
+			name: "bitfield bytes",
+			t: &c.StructType{Size: 4, Tag: "s", Fields: []c.Field{
+				{BitOffset: 0, BitWidth: 8, Var: c.Var{Type: c.UInt, Name: "a"}},
+				{BitOffset: 16, BitWidth: 8, Var: c.Var{Type: c.UInt, Name: "b"}},
+			}},
+			want: []string{"char pad_0001[1];"},
+		},
+	}
+	for _, g := range golden {
+		var got []string
+		for _, line := range strings.Split(g.t.Def(), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "char pad_") || strings.HasPrefix(line, "unsigned int :") {
+				got = append(got, line)
+			}
+		}
+		if strings.Join(got, "\n") != strings.Join(g.want, "\n") {
+			t.Errorf("%s: padding mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
+
+func TestLayoutAsserts(t *testing.T) {
+	s := &c.StructType{Size: 8, Tag: "s", Fields: []c.Field{
+		{Offset: 0, Size: 1, Var: c.Var{Type: c.Char, Name: "a"}},
+		{BitOffset: 8, BitWidth: 3, Var: c.Var{Type: c.UInt, Name: "b"}},
+		{Offset: 4, Size: 4, Var: c.Var{Type: c.Int, Name: "c"}},
+	}}
+	want := []string{
+		`_Static_assert(sizeof(struct s) == 0x8, "size of struct s")`,
+		`_Static_assert(offsetof(struct s, a) == 0x0, "offset of struct s.a")`,
+		`_Static_assert(offsetof(struct s, c) == 0x4, "offset of struct s.c")`,
+	}
+	if got := c.LayoutAsserts(s); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("struct layout asserts mismatch; expected %q, got %q", want, got)
+	}
+	u := &c.UnionType{Size: 4, Tag: "u", Fields: []c.Field{
+		{Var: c.Var{Type: c.Int, Name: "a"}},
+	}}
+	want = []string{`_Static_assert(sizeof(union u) == 0x4, "size of union u")`}
+	if got := c.LayoutAsserts(u); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("union layout asserts mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestStdParam(t *testing.T) {
+	// void f(int a, double b, int c, int d);
+	f := &c.FuncDecl{
+		Frame: &c.Frame{Size: 24},
+		Var: c.Var{
+			Type: &c.FuncType{
+				RetType: c.Void,
+				Params: []*c.VarDecl{
+					{Class: c.Register, Addr: c.RegA0, Var: c.Var{Type: c.Int, Name: "a"}},
+					{Class: c.Auto, Addr: 24 + 8, Var: c.Var{Type: c.Double, Name: "b"}},
+					{Class: c.Auto, Addr: 24 + 16, Var: c.Var{Type: c.Int, Name: "c"}},
+					{Class: c.Auto, Addr: 24 + 24, Var: c.Var{Type: c.Int, Name: "d"}},
+				},
+			},
+			Name: "f",
+		},
+	}
+	golden := []struct {
+		class c.StorageClass
+		addr  uint32
+		std   bool
+	}{
+		{class: c.Register, addr: c.RegA0, std: true},
+		// Doubleword aligned to $a2.
+		{class: c.Register, addr: c.RegA0 + 2, std: true},
+		{class: c.Auto, addr: 24 + 16, std: true},
+		// Stored at a different stack offset than the convention.
+		{class: c.Auto, addr: 24 + 20, std: false},
+	}
+	for i, g := range golden {
+		class, addr, ok := f.StdParam(i)
+		if !ok || class != g.class || addr != g.addr {
+			t.Errorf("parameter %d: storage mismatch; expected %v 0x%X, got %v 0x%X (%v)", i, g.class, g.addr, class, addr, ok)
+		}
+		if std := f.IsStdParam(i); std != g.std {
+			t.Errorf("parameter %d: expected standard %v, got %v", i, g.std, std)
+		}
+	}
+	if f.IsStdCall() {
+		t.Errorf("expected non-standard calling convention")
+	}
+	if _, _, ok := f.StdParam(4); ok || f.IsStdParam(4) {
+		t.Errorf("expected no parameter past the last one")
+	}
+	v := &c.FuncDecl{Var: c.Var{Type: c.Int, Name: "v"}}
+	if _, _, ok := v.StdParam(0); ok || v.IsStdParam(0) || !v.IsStdCall() {
+		t.Errorf("expected no parameters of non-function type")
+	}
+}
@@ -0,0 +1,257 @@
+package c
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MIPS register names, in order of register number.
+var regNames = [...]string{
+	"zero", "at", "v0", "v1", "a0", "a1", "a2", "a3",
+	"t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7",
+	"s0", "s1", "s2", "s3", "s4", "s5", "s6", "s7",
+	"t8", "t9", "k0", "k1", "gp", "sp", "fp", "ra",
+}
+
+// MIPS registers of special use.
+const (
+	// Return value register.
+	RegV0 = 2
+	// First argument register.
+	RegA0 = 4
+	// Stack pointer register.
+	RegSP = 29
+	// Return address register.
+	RegRA = 31
+)
+
+// RegName returns the assembler name of the given MIPS register (e.g. $s0).
+func RegName(reg uint32) string {
+	if reg < uint32(len(regNames)) {
+		return "$" + regNames[reg]
+	}
+	return fmt.Sprintf("$%d", reg)
+}
+
+// A Frame is the stack frame of a function.
+type Frame struct {
+	// Frame pointer register.
+	FP uint32
+	// Frame size in bytes.
+	Size uint32
+	// Return address register.
+	RetReg uint32
+	// Mask of the registers saved in the frame; bit n is set if register n is
+	// saved.
+	Mask uint32
+	// Offset of the highest numbered saved register, relative to the top of
+	// the frame.
+	MaskOffset int32
+}
+
+// A FrameSlot is a variable or saved register stored in a stack frame.
+type FrameSlot struct {
+	// Offset relative to the frame pointer.
+	Offset int32
+	// Size in bytes.
+	Size uint32
+	// Variable name, or register name of saved registers.
+	Name string
+	// Variable type; nil for saved registers.
+	Type Type
+	// Saved register number (saved registers only).
+	Reg uint32
+	// Function argument passed on the stack.
+	Arg bool
+}
+
+// SavedRegs returns the registers saved in the frame, with the highest numbered
+// register stored at the highest offset.
+func (f *Frame) SavedRegs() []FrameSlot {
+	var slots []FrameSlot
+	offset := int32(f.Size) + f.MaskOffset
+	for reg := int32(31); reg >= 0; reg-- {
+		if f.Mask&(1<<uint(reg)) == 0 {
+			continue
+		}
+		slot := FrameSlot{
+			Offset: offset,
+			Size:   4,
+			Name:   RegName(uint32(reg)),
+			Reg:    uint32(reg),
+		}
+		slots = append(slots, slot)
+		offset -= 4
+	}
+	return slots
+}
+
+// FrameSlots returns the stack frame layout of the function, ordered by offset;
+// saved registers, local variables and arguments stored on the stack. It
+// returns nil if the frame of the function is unknown.
+func (f *FuncDecl) FrameSlots() []FrameSlot {
+	if f.Frame == nil {
+		return nil
+	}
+	slots := f.Frame.SavedRegs()
+	if t, ok := f.Type.(*FuncType); ok {
+		for _, param := range t.Params {
+			if !onStack(param) {
+				continue
+			}
+			slot := FrameSlot{
+				Offset: int32(param.Addr),
+				Size:   Sizeof(param.Type),
+				Name:   param.Name,
+				Type:   param.Type,
+				Arg:    true,
+			}
+			slots = append(slots, slot)
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, local := range block.Locals {
+			if local.Class != Auto {
+				continue
+			}
+			slot := FrameSlot{
+				Offset: int32(local.Addr),
+				Size:   Sizeof(local.Type),
+				Name:   local.Name,
+				Type:   local.Type,
+			}
+			slots = append(slots, slot)
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].Offset < slots[j].Offset
+	})
+	return slots
+}
+
+// frameDef returns the C comment describing the stack frame of the function.
+func (f *FuncDecl) frameDef() string {
+	if f.Frame == nil {
+		return ""
+	}
+	s := fmt.Sprintf("// frame: 0x%X bytes, %s based, return address in %s\n", f.Frame.Size, RegName(f.Frame.FP), RegName(f.Frame.RetReg))
+	base := regNames[RegSP]
+	if f.Frame.FP < uint32(len(regNames)) {
+		base = regNames[f.Frame.FP]
+	}
+	for _, slot := range f.FrameSlots() {
+		switch {
+		case slot.Type == nil:
+			s += fmt.Sprintf("//    %s%+#x: saved %s\n", base, slot.Offset, slot.Name)
+		case slot.Arg:
+			s += fmt.Sprintf("//    %s%+#x: %s (argument)\n", base, slot.Offset, slot.Name)
+		default:
+			s += fmt.Sprintf("//    %s%+#x: %s\n", base, slot.Offset, slot.Name)
+		}
+	}
+	return s
+}
+
+// onStack reports whether the given parameter is passed on the stack.
+func onStack(param *VarDecl) bool {
+	return param.Class != Register
+}
+
+// --- [ Calling convention ] --------------------------------------------------
+
+// Number of argument registers of the MIPS o32 calling convention ($a0-$a3).
+const nargRegs = 4
+
+// StdParam returns the storage class and register number or stack offset of
+// the i:th parameter of the function under the MIPS o32 calling convention;
+// the first four words of arguments are passed in $a0-$a3 and the remaining
+// ones on the stack, above the frame of the function. Stack offsets are
+// relative to the frame pointer, and only known if the frame of the function
+// is. The boolean result is false if the function has no such parameter.
+func (f *FuncDecl) StdParam(i int) (StorageClass, uint32, bool) {
+	t, ok := f.Type.(*FuncType)
+	if !ok || i < 0 || i >= len(t.Params) {
+		return 0, 0, false
+	}
+	word := uint32(0)
+	for j := 0; j <= i; j++ {
+		size := Sizeof(t.Params[j].Type)
+		if size > 4 && size <= 8 && word%2 != 0 {
+			// Doubleword arguments are aligned to even words.
+			word++
+		}
+		if j == i {
+			break
+		}
+		word += (size + 3) / 4
+	}
+	if word < nargRegs {
+		return Register, RegA0 + word, true
+	}
+	var offset uint32
+	if f.Frame != nil {
+		offset = f.Frame.Size + 4*word
+	}
+	return Auto, offset, true
+}
+
+// IsStdParam reports whether the i:th parameter of the function is stored
+// according to the MIPS o32 calling convention; false if the function has no
+// such parameter.
+func (f *FuncDecl) IsStdParam(i int) bool {
+	class, addr, ok := f.StdParam(i)
+	if !ok {
+		return false
+	}
+	param := f.Type.(*FuncType).Params[i]
+	switch param.Class {
+	case Register:
+		return class == Register && param.Addr == addr
+	case Auto:
+		// Arguments passed in registers may be spilled to their home location
+		// on the stack.
+		if f.Frame == nil {
+			return true
+		}
+		if class == Register {
+			addr = f.Frame.Size + 4*(addr-RegA0)
+		}
+		return param.Addr == addr
+	}
+	// Storage unknown.
+	return true
+}
+
+// IsStdCall reports whether all parameters of the function are stored
+// according to the MIPS o32 calling convention.
+func (f *FuncDecl) IsStdCall() bool {
+	t, ok := f.Type.(*FuncType)
+	if !ok {
+		return true
+	}
+	for i := range t.Params {
+		if !f.IsStdParam(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// paramsDef returns the C comment describing the storage of the parameters of
+// the function, if not according to the MIPS o32 calling convention.
+func (f *FuncDecl) paramsDef() string {
+	if f.IsStdCall() {
+		return ""
+	}
+	t := f.Type.(*FuncType)
+	s := "// non-standard parameter storage:\n"
+	for _, param := range t.Params {
+		switch param.Class {
+		case Register:
+			s += fmt.Sprintf("//    %s: %s\n", param.Name, RegName(param.Addr))
+		case Auto:
+			s += fmt.Sprintf("//    %s: stack offset %+#x\n", param.Name, int32(param.Addr))
+		}
+	}
+	return s
+}
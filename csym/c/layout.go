@@ -0,0 +1,208 @@
+package c
+
+import (
+	"fmt"
+)
+
+// Type sizes and alignments follow the MIPS ABI used by the PSX compilers.
+
+// Sizeof returns the size in bytes of the given type. The size of structs and
+// unions is taken from the SYM file if present.
+func Sizeof(t Type) uint32 {
+	switch t := t.(type) {
+	case BaseType:
+		switch t {
+		case Char, UChar:
+			return 1
+		case Short, UShort:
+			return 2
+		case Int, Long, UInt, ULong, Float:
+			return 4
+		case Double:
+			return 8
+		}
+		return 0
+	case *StructType:
+		if t.Size > 0 {
+			return t.Size
+		}
+		return alignUp(fieldsEnd(t.Fields), Alignof(t))
+	case *UnionType:
+		if t.Size > 0 {
+			return t.Size
+		}
+		return alignUp(fieldsEnd(t.Fields), Alignof(t))
+	case *EnumType:
+		return 4
+	case *PointerType:
+		return 4
+	case *ArrayType:
+		return uint32(t.Len) * Sizeof(t.Elem)
+	case *TypedefType:
+		return Sizeof(t.Typedef.Type)
+	}
+	return 0
+}
+
+// Alignof returns the alignment in bytes of the given type.
+func Alignof(t Type) uint32 {
+	switch t := t.(type) {
+	case BaseType:
+		if size := Sizeof(t); size > 0 {
+			return size
+		}
+		return 1
+	case *StructType:
+		return fieldsAlign(t.Fields)
+	case *UnionType:
+		return fieldsAlign(t.Fields)
+	case *EnumType, *PointerType, *FuncType:
+		return 4
+	case *ArrayType:
+		return Alignof(t.Elem)
+	case *TypedefType:
+		return Alignof(t.Typedef.Type)
+	}
+	return 1
+}
+
+// LayoutAsserts returns C11 static assertions checking that the size of the
+// given struct or union type and the offsets of its fields match the SYM file.
+// Bitfields are not checked, as their offsets cannot be taken.
+func LayoutAsserts(t Type) []string {
+	var asserts []string
+	switch t := t.(type) {
+	case *StructType:
+		if t.Size > 0 {
+			asserts = append(asserts, fmt.Sprintf("_Static_assert(sizeof(%s) == 0x%X, \"size of %s\")", t, t.Size, t))
+		}
+		for _, field := range t.Fields {
+			if field.BitWidth > 0 || len(field.Name) == 0 {
+				continue
+			}
+			asserts = append(asserts, fmt.Sprintf("_Static_assert(offsetof(%s, %s) == 0x%X, \"offset of %s.%s\")", t, field.Name, field.Offset, t, field.Name))
+		}
+	case *UnionType:
+		if t.Size > 0 {
+			asserts = append(asserts, fmt.Sprintf("_Static_assert(sizeof(%s) == 0x%X, \"size of %s\")", t, t.Size, t))
+		}
+	}
+	return asserts
+}
+
+// ### [ Helper functions ] ####################################################
+
+// A padding is a gap in a struct not accounted for by the alignment of fields.
+type padding struct {
+	// Offset in bytes, or in bits for bitfields.
+	Offset uint32
+	// Size in bytes, or in bits for bitfields.
+	Size uint32
+	// Bitfield padding.
+	Bits bool
+}
+
+// fieldPadding returns the padding required before the given field, to place
+// it at the offset recorded in the SYM file; end specifies the end of the
+// preceding fields in bits.
+func fieldPadding(end uint32, field Field) (padding, bool) {
+	if field.BitWidth > 0 {
+		// Bitfields are placed at the current bit offset, unless crossing the
+		// boundary of their storage unit.
+		unit := Sizeof(field.Type) * 8
+		if unit == 0 {
+			unit = 32
+		}
+		pos := end
+		if pos/unit != (pos+field.BitWidth-1)/unit {
+			pos = alignUp(pos, unit)
+		}
+		if field.BitOffset <= pos {
+			return padding{}, false
+		}
+		if end%8 == 0 && field.BitOffset%8 == 0 {
+			pad := padding{
+				Offset: end / 8,
+				Size:   (field.BitOffset - end) / 8,
+			}
+			return pad, true
+		}
+		if field.BitOffset-end > unit {
+			// Unable to pad using an unnamed bitfield.
+			return padding{}, false
+		}
+		pad := padding{
+			Offset: end,
+			Size:   field.BitOffset - end,
+			Bits:   true,
+		}
+		return pad, true
+	}
+	start := bytesEnd(end)
+	if field.Offset <= alignUp(start, Alignof(field.Type)) {
+		return padding{}, false
+	}
+	pad := padding{
+		Offset: start,
+		Size:   field.Offset - start,
+	}
+	return pad, true
+}
+
+// tailPadding returns the padding required at the end of the given struct, to
+// reach the size recorded in the SYM file; end specifies the end of the
+// struct fields in bits.
+func tailPadding(end uint32, t *StructType) (padding, bool) {
+	start := bytesEnd(end)
+	if t.Size <= alignUp(start, Alignof(t)) {
+		return padding{}, false
+	}
+	pad := padding{
+		Offset: start,
+		Size:   t.Size - start,
+	}
+	return pad, true
+}
+
+// fieldEnd returns the end offset in bits of the given field.
+func fieldEnd(field Field) uint32 {
+	if field.BitWidth > 0 {
+		return field.BitOffset + field.BitWidth
+	}
+	return (field.Offset + Sizeof(field.Type)) * 8
+}
+
+// fieldsEnd returns the end offset in bytes of the given fields.
+func fieldsEnd(fields []Field) uint32 {
+	var end uint32
+	for _, field := range fields {
+		if e := fieldEnd(field); e > end {
+			end = e
+		}
+	}
+	return bytesEnd(end)
+}
+
+// fieldsAlign returns the largest alignment of the given fields.
+func fieldsAlign(fields []Field) uint32 {
+	align := uint32(1)
+	for _, field := range fields {
+		if a := Alignof(field.Type); a > align {
+			align = a
+		}
+	}
+	return align
+}
+
+// bytesEnd returns the end offset in bytes of the given end offset in bits.
+func bytesEnd(end uint32) uint32 {
+	return (end + 7) / 8
+}
+
+// alignUp rounds x up to a multiple of align.
+func alignUp(x, align uint32) uint32 {
+	if align == 0 {
+		return x
+	}
+	return (x + align - 1) / align * align
+}
@@ -33,6 +33,8 @@ const (
 	UShort                     // unsigned short
 	UInt                       // unsigned int
 	ULong                      // unsigned long
+	Float                      // float
+	Double                     // double
 )
 
 // Def returns the C syntax representation of the definition of the type.
@@ -51,6 +53,9 @@ type StructType struct {
 	// Structure fields.
 	Fields []Field
 	// Struct methods.
+	//
+	// Deprecated: FIELD symbols are bitfields, recorded in Fields; Methods is
+	// not set by the parser, nor used.
 	Methods []Field
 }
 
@@ -70,28 +75,43 @@ func (t *StructType) Def() string {
 	} else {
 		buf.WriteString("struct {\n")
 	}
+	// End of the preceding fields in bits.
+	var end uint32
 	for _, field := range t.Fields {
-		if field.Size > 0 {
+		// Add explicit padding where the offsets of the SYM file leave gaps.
+		if pad, ok := fieldPadding(end, field); ok {
+			writePadding(buf, pad)
+		}
+		if e := fieldEnd(field); e > end {
+			end = e
+		}
+		if field.BitWidth > 0 {
+			fmt.Fprintf(buf, "\t// offset: %04X.%d (%d bits)\n", field.BitOffset/8, field.BitOffset%8, field.BitWidth)
+		} else if field.Size > 0 {
 			fmt.Fprintf(buf, "\t// offset: %04X (%d bytes)\n", field.Offset, field.Size)
 		} else if len(t.Fields) > 1 && t.Fields[1].Offset > 0 {
 			fmt.Fprintf(buf, "\t// offset: %04X\n", field.Offset)
 		}
 		fmt.Fprintf(buf, "\t%s;\n", field)
 	}
-	// TODO: Figure out how to print methods in a good way; for now, commented
-	// out.
-	for _, method := range t.Methods {
-		if method.Size > 0 {
-			fmt.Fprintf(buf, "\t// offset: %04X (%d bytes)\n", method.Offset, method.Size)
-		} else if len(t.Fields) > 1 && t.Fields[1].Offset > 0 {
-			fmt.Fprintf(buf, "\t// offset: %04X\n", method.Offset)
-		}
-		fmt.Fprintf(buf, "\t// %s;\n", method)
+	if pad, ok := tailPadding(end, t); ok {
+		writePadding(buf, pad)
 	}
 	buf.WriteString("}")
 	return buf.String()
 }
 
+// writePadding writes the padding member of a struct to buf.
+func writePadding(buf *strings.Builder, pad padding) {
+	if pad.Bits {
+		fmt.Fprintf(buf, "\t// offset: %04X.%d (%d bits)\n", pad.Offset/8, pad.Offset%8, pad.Size)
+		fmt.Fprintf(buf, "\tunsigned int : %d;\n", pad.Size)
+		return
+	}
+	fmt.Fprintf(buf, "\t// offset: %04X (%d bytes)\n", pad.Offset, pad.Size)
+	fmt.Fprintf(buf, "\tchar pad_%04X[%d];\n", pad.Offset, pad.Size)
+}
+
 // --- [ Union type ] ---------------------------------------------------------
 
 // UnionType is a union type.
@@ -121,7 +141,9 @@ func (t *UnionType) Def() string {
 		buf.WriteString("union {\n")
 	}
 	for _, field := range t.Fields {
-		if field.Size > 0 {
+		if field.BitWidth > 0 {
+			fmt.Fprintf(buf, "\t// offset: %04X.%d (%d bits)\n", field.BitOffset/8, field.BitOffset%8, field.BitWidth)
+		} else if field.Size > 0 {
 			fmt.Fprintf(buf, "\t// offset: %04X (%d bytes)\n", field.Offset, field.Size)
 		} else if len(t.Fields) > 1 && t.Fields[1].Offset > 0 {
 			fmt.Fprintf(buf, "\t// offset: %04X\n", field.Offset)
@@ -248,6 +270,24 @@ func (t *FuncType) Def() string {
 	return t.String()
 }
 
+// --- [ Typedef type ] --------------------------------------------------------
+
+// TypedefType is a type referred to by typedef name.
+type TypedefType struct {
+	// Type definition.
+	Typedef *VarDecl
+}
+
+// String returns the string representation of the typedef type.
+func (t *TypedefType) String() string {
+	return t.Typedef.Name
+}
+
+// Def returns the C syntax representation of the definition of the type.
+func (t *TypedefType) Def() string {
+	return t.String()
+}
+
 // ### [ Helper types ] ########################################################
 
 // A Field represents a field in a structure type or union type.
@@ -256,10 +296,22 @@ type Field struct {
 	Offset uint32
 	// Size in bytes (optional).
 	Size uint32
+	// Offset in bits (bitfields only).
+	BitOffset uint32
+	// Width in bits; zero if not a bitfield.
+	BitWidth uint32
 	// Underlying variable.
 	Var
 }
 
+// String returns the string representation of the field.
+func (f Field) String() string {
+	if f.BitWidth > 0 {
+		return fmt.Sprintf("%s : %d", f.Var, f.BitWidth)
+	}
+	return f.Var.String()
+}
+
 // A Var represents a variable declaration or function parameter.
 type Var struct {
 	// Variable type.
@@ -330,7 +382,9 @@ func fakeUnionString(t *UnionType) string {
 	}
 	buf.WriteString("\tunion {\n")
 	for _, field := range t.Fields {
-		if field.Size > 0 {
+		if field.BitWidth > 0 {
+			fmt.Fprintf(buf, "\t\t// offset: %04X.%d (%d bits)\n", field.BitOffset/8, field.BitOffset%8, field.BitWidth)
+		} else if field.Size > 0 {
 			fmt.Fprintf(buf, "\t\t// offset: %04X (%d bytes)\n", field.Offset, field.Size)
 		} else if len(t.Fields) > 1 && t.Fields[1].Offset > 0 {
 			fmt.Fprintf(buf, "\t\t// offset: %04X\n", field.Offset)
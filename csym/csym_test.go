@@ -0,0 +1,238 @@
+package csym_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mefistotelis/psx_mnd_sym"
+	"github.com/mefistotelis/psx_mnd_sym/csym"
+	"github.com/mefistotelis/psx_mnd_sym/csym/c"
+)
+
+func TestOrderTypesForward(t *testing.T) {
+	// struct A { struct B *b; }; struct B { struct A *a; int x; };
+	p := csym.NewParser(&sym.Options{})
+	a := p.AddStruct(&c.StructType{Size: 4, Tag: "A"})
+	b := p.AddStruct(&c.StructType{Size: 8, Tag: "B"})
+	a.Fields = []c.Field{
+		{Offset: 0, Size: 4, Var: c.Var{Type: &c.PointerType{Elem: b}, Name: "b"}},
+	}
+	b.Fields = []c.Field{
+		{Offset: 0, Size: 4, Var: c.Var{Type: &c.PointerType{Elem: a}, Name: "a"}},
+		{Offset: 4, Size: 4, Var: c.Var{Type: c.Int, Name: "x"}},
+	}
+	order := p.OrderTypes()
+	if got, want := typeNames(order.Defs), "struct A, struct B"; got != want {
+		t.Errorf("definitions mismatch; expected %q, got %q", want, got)
+	}
+	if got, want := typeNames(order.Forward), "struct B"; got != want {
+		t.Errorf("forward declarations mismatch; expected %q, got %q", want, got)
+	}
+	if len(order.Cycles) != 0 {
+		t.Errorf("unexpected dependency cycles; got %v", order.Cycles)
+	}
+}
+
+func TestOrderTypesCycle(t *testing.T) {
+	// struct A { struct B b; }; struct B { struct A a; };
+	p := csym.NewParser(&sym.Options{})
+	a := p.AddStruct(&c.StructType{Size: 4, Tag: "A"})
+	b := p.AddStruct(&c.StructType{Size: 4, Tag: "B"})
+	a.Fields = []c.Field{
+		{Offset: 0, Size: 4, Var: c.Var{Type: b, Name: "b"}},
+	}
+	b.Fields = []c.Field{
+		{Offset: 0, Size: 4, Var: c.Var{Type: a, Name: "a"}},
+	}
+	order := p.OrderTypes()
+	if len(order.Cycles) != 1 {
+		t.Fatalf("dependency cycle count mismatch; expected 1, got %d", len(order.Cycles))
+	}
+	if got, want := csym.CycleString(order.Cycles[0]), "struct A -> struct B -> struct A"; got != want {
+		t.Errorf("dependency cycle mismatch; expected %q, got %q", want, got)
+	}
+	// Each type is defined once even if part of a cycle.
+	if got, want := typeNames(order.Defs), "struct B, struct A"; got != want {
+		t.Errorf("definitions mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestRemoveDuplicateTypesTypedefs(t *testing.T) {
+	// struct A { int x; }; typedef struct A *PA; typedef struct A B; where
+	// the typedefs refer to a duplicate of struct A.
+	p := csym.NewParser(&sym.Options{})
+	fields := func() []c.Field {
+		return []c.Field{{Offset: 0, Size: 4, Var: c.Var{Type: c.Int, Name: "x"}}}
+	}
+	a1 := p.AddStruct(&c.StructType{Size: 4, Tag: "A", Fields: fields()})
+	a2 := p.AddStruct(&c.StructType{Size: 4, Tag: "A", Fields: fields()})
+	pa := &c.VarDecl{Class: c.Typedef, Var: c.Var{Type: &c.PointerType{Elem: a2}, Name: "PA"}}
+	b := &c.VarDecl{Class: c.Typedef, Var: c.Var{Type: a2, Name: "B"}}
+	p.Typedefs = append(p.Typedefs, pa, b)
+	p.RemoveDuplicateTypes()
+	if len(p.Structs) != 1 || p.Structs[0] != a1 {
+		t.Fatalf("structs mismatch; expected [%v], got %v", a1, p.Structs)
+	}
+	if elem := pa.Type.(*c.PointerType).Elem; elem != a1 {
+		t.Errorf("typedef PA refers to removed duplicate of struct A")
+	}
+	if b.Type != a1 {
+		t.Errorf("typedef B refers to removed duplicate of struct A")
+	}
+}
+
+func TestReplaceUsedTypesTypedefName(t *testing.T) {
+	// typedef struct A A; struct A referred to by the typedef name A elsewhere,
+	// except in the typedef itself.
+	p := csym.NewParser(&sym.Options{})
+	a := p.AddStruct(&c.StructType{Size: 4, Tag: "A"})
+	def := &c.VarDecl{Class: c.Typedef, Var: c.Var{Type: a, Name: "A"}}
+	pa := &c.VarDecl{Class: c.Typedef, Var: c.Var{Type: &c.PointerType{Elem: a}, Name: "PA"}}
+	p.Typedefs = append(p.Typedefs, def, pa)
+	p.ReplaceUsedTypes(map[c.Type]c.Type{a: &c.TypedefType{Typedef: def}})
+	if def.Type != a {
+		t.Errorf("typedef A mismatch; expected %v, got %v", a, def.Type)
+	}
+	if got, want := pa.Def(), "typedef A *PA"; got != want {
+		t.Errorf("typedef PA mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	newParser := func(bField c.Field, vars, funcs []string, ovlVars map[uint32][]string) *csym.Parser {
+		p := csym.NewParser(&sym.Options{})
+		a := p.AddStruct(&c.StructType{Size: 4, Tag: "A", Fields: []c.Field{
+			{Offset: 0, Size: 4, Var: c.Var{Type: c.Int, Name: "x"}},
+		}})
+		p.AddStruct(&c.StructType{Size: 4, Tag: "B", Fields: []c.Field{bField}})
+		for i, name := range vars {
+			v := &c.VarDecl{Addr: 0x80100000 + uint32(i)*4, Size: 4, Class: c.Extern, Var: c.Var{Type: a, Name: name}}
+			p.Overlay.Vars = append(p.Overlay.Vars, v)
+		}
+		for _, name := range funcs {
+			f := &c.FuncDecl{Addr: 0x80010000, Size: 8, Var: c.Var{Type: &c.FuncType{RetType: c.Void}, Name: name}}
+			if name == "k" {
+				f.Addr = 0x80010010
+			}
+			p.Overlay.Funcs = append(p.Overlay.Funcs, f)
+		}
+		for id, names := range ovlVars {
+			overlay := &csym.Overlay{Addr: 0x80200000, ID: id}
+			for _, name := range names {
+				v := &c.VarDecl{Addr: 0x80200000, Size: 4, Class: c.Extern, Var: c.Var{Type: c.Int, Name: name}}
+				overlay.Vars = append(overlay.Vars, v)
+			}
+			p.Overlays = append(p.Overlays, overlay)
+		}
+		return p
+	}
+	// struct B differs between the parsers; g and f are shared; o is shared
+	// by overlay 1 of both parsers.
+	p1 := newParser(c.Field{Offset: 0, Size: 4, Var: c.Var{Type: c.Int, Name: "y"}}, []string{"g"}, []string{"f"}, map[uint32][]string{1: {"o"}})
+	p2 := newParser(c.Field{Offset: 0, Size: 1, Var: c.Var{Type: c.Char, Name: "z"}}, []string{"g", "h"}, []string{"f", "k"}, map[uint32][]string{1: {"o"}})
+	p2.Overlays = append(p2.Overlays, &csym.Overlay{Addr: 0x80200000, ID: 2, Vars: []*c.VarDecl{
+		{Addr: 0x80200000, Size: 4, Class: c.Extern, Var: c.Var{Type: c.Int, Name: "o"}},
+	}})
+	m, err := csym.Merge([]*csym.Parser{p1, p2}, csym.MergeOptions{})
+	if err != nil {
+		t.Fatalf("unable to merge parsers; %v", err)
+	}
+	var tags []string
+	for _, s := range m.Structs {
+		tags = append(tags, s.Tag)
+	}
+	if len(tags) != 3 || tags[0] != "A" || tags[1] != "B" || tags[2] == "B" {
+		t.Errorf("struct tags mismatch; expected [A B <unique B>], got %v", tags)
+	}
+	if origins := m.Origins[m.Structs[0]]; len(origins) != 2 {
+		t.Errorf("origins of struct A mismatch; expected [0 1], got %v", origins)
+	}
+	var vars, funcs []string
+	for _, v := range m.Overlay.Vars {
+		vars = append(vars, v.Name)
+		if v.Type != m.Structs[0] {
+			t.Errorf("type of variable %s not mapped to merged struct A; got %v", v.Name, v.Type)
+		}
+	}
+	for _, f := range m.Overlay.Funcs {
+		funcs = append(funcs, f.Name)
+	}
+	if got, want := strings.Join(vars, " "), "g h"; got != want {
+		t.Errorf("variables mismatch; expected %q, got %q", want, got)
+	}
+	if got, want := strings.Join(funcs, " "), "f k"; got != want {
+		t.Errorf("functions mismatch; expected %q, got %q", want, got)
+	}
+	if origins := m.Origins[m.Overlay.Funcs[0]]; len(origins) != 2 {
+		t.Errorf("origins of function f mismatch; expected [0 1], got %v", origins)
+	}
+	// Declarations are merged per overlay.
+	if len(m.Overlays) != 2 {
+		t.Fatalf("overlay count mismatch; expected 2, got %d", len(m.Overlays))
+	}
+	for _, overlay := range m.Overlays {
+		if len(overlay.Vars) != 1 {
+			t.Errorf("overlay %x: variable count mismatch; expected 1, got %d", overlay.ID, len(overlay.Vars))
+		}
+	}
+	if len(m.Inputs) != 2 || len(m.Inputs[1].Overlay.Vars) != 2 {
+		t.Errorf("input declarations not kept")
+	}
+}
+
+func TestClassifySymbols(t *testing.T) {
+	sy := func(value uint32, kind sym.Kind, body sym.SymbolBody) *sym.Symbol {
+		return &sym.Symbol{Hdr: &sym.SymbolHeader{Value: value, Kind: kind}, Body: body}
+	}
+	syms := []*sym.Symbol{
+		sy(0x80010000, sym.KindFuncStart, &sym.FuncStart{FP: 29, RetReg: 31, Line: 1, PathLen: 3, Path: "A.C", NameLen: 4, Name: "main"}),
+		sy(0x80010040, sym.KindFuncEnd, &sym.FuncEnd{Line: 2}),
+		sy(0x800A0000, sym.KindDef, &sym.Def{Class: sym.ClassEXT, Type: 0x4, Size: 4, NameLen: 1, Name: "g"}),
+		// Library function without type information, named like a linker
+		// constant of old.
+		sy(0x80012000, sym.KindName2, &sym.Name2{NameLen: 9, Name: "CdGetSize"}),
+		sy(0x800, sym.KindName1, &sym.Name1{NameLen: 10, Name: "_stacksize"}),
+		sy(0x1F800000, sym.KindName1, &sym.Name1{NameLen: 12, Name: "__SN_SCRATCH"}),
+		// Linker constants with addresses of main RAM are addresses.
+		sy(0x800B0000, sym.KindName1, &sym.Name1{NameLen: 12, Name: "__SN_GP_BASE"}),
+		sy(0x800A0100, sym.KindName5, &sym.Name5{NameLen: 6, Name: "s_data"}),
+	}
+	p := csym.NewParser(&sym.Options{})
+	p.ParseTypes(syms)
+	p.ParseDecls(syms)
+	want := map[string]csym.SymbolKind{
+		"CdGetSize":    csym.SymbolCode,
+		"_stacksize":   csym.SymbolAbs,
+		"__SN_SCRATCH": csym.SymbolAbs,
+		"__SN_GP_BASE": csym.SymbolData,
+		"s_data":       csym.SymbolData,
+	}
+	got := make(map[string]csym.SymbolKind)
+	for _, s := range p.Overlay.Symbols {
+		got[s.Name] = s.Kind
+	}
+	for name, kind := range want {
+		if got[name] != kind {
+			t.Errorf("symbol %s: kind mismatch; expected %v, got %v", name, kind, got[name])
+		}
+	}
+	// Absolute symbols are not addresses.
+	var names []string
+	for _, s := range p.Overlay.UntypedAddrs() {
+		names = append(names, s.Name)
+	}
+	if got, want := strings.Join(names, " "), "CdGetSize __SN_GP_BASE s_data"; got != want {
+		t.Errorf("untyped addresses mismatch; expected %q, got %q", want, got)
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// typeNames returns a comma-separated list of the given types.
+func typeNames(types []c.Type) string {
+	var names []string
+	for _, t := range types {
+		names = append(names, t.String())
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,309 @@
+package csym
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mefistotelis/psx_mnd_sym/csym/c"
+	"github.com/pkg/errors"
+)
+
+// jsonModel is the JSON representation of the types and declarations of a
+// parser.
+type jsonModel struct {
+	// Type definitions in order of dependency.
+	Types []*jsonTypeDef
+	// Default binary followed by overlays.
+	Overlays []*jsonOverlay
+}
+
+// jsonTypeDef is the JSON representation of a struct, union, enum or typedef
+// definition.
+type jsonTypeDef struct {
+	// Kind of definition; struct, union, enum or typedef.
+	Kind string
+	// Tag, or typedef name.
+	Name string
+	// Size in bytes (structs and unions only).
+	Size uint32 `json:",omitempty"`
+	// Struct and union fields.
+	Fields []*jsonField `json:",omitempty"`
+	// Enum members.
+	Members []*c.EnumMember `json:",omitempty"`
+	// Underlying type (typedefs only).
+	Type *jsonType `json:",omitempty"`
+}
+
+// jsonField is the JSON representation of a struct or union field.
+type jsonField struct {
+	// Field name.
+	Name string
+	// Offset in bytes.
+	Offset uint32
+	// Offset in bits (bitfields only).
+	BitOffset uint32 `json:",omitempty"`
+	// Width in bits (bitfields only).
+	BitWidth uint32 `json:",omitempty"`
+	// Field type.
+	Type *jsonType
+}
+
+// jsonType is the JSON representation of a type.
+type jsonType struct {
+	// Kind of type; base, struct, union, enum, typedef, pointer, array or func.
+	Kind string
+	// Base type name, tag, or typedef name.
+	Name string `json:",omitempty"`
+	// Array length.
+	Len int `json:",omitempty"`
+	// Element type of pointers and arrays.
+	Elem *jsonType `json:",omitempty"`
+	// Return type of functions.
+	RetType *jsonType `json:",omitempty"`
+	// Function parameters.
+	Params []*jsonVar `json:",omitempty"`
+	// Variadic function.
+	Variadic bool `json:",omitempty"`
+}
+
+// jsonVar is the JSON representation of a variable declaration.
+type jsonVar struct {
+	// Variable name.
+	Name string
+	// Storage class.
+	Class string `json:",omitempty"`
+	// Address, frame pointer delta, or register depending on storage class.
+	Addr uint32
+	// Size in bytes.
+	Size uint32 `json:",omitempty"`
+	// Variable type.
+	Type *jsonType
+}
+
+// jsonFunc is the JSON representation of a function declaration.
+type jsonFunc struct {
+	// Function name.
+	Name string
+	// Source file.
+	Path string
+	// Start address.
+	Addr uint32
+	// End address.
+	AddrEnd uint32
+	// Size in bytes.
+	Size uint32
+	// Start line number.
+	LineStart uint32
+	// End line number.
+	LineEnd uint32
+	// Function type.
+	Type *jsonType
+	// Stack frame.
+	Frame *c.Frame `json:",omitempty"`
+	// Scope blocks.
+	Blocks []*jsonBlock
+}
+
+// jsonBlock is the JSON representation of a scope block.
+type jsonBlock struct {
+	// Start line number (relative to the function).
+	LineStart uint32
+	// End line number (relative to the function).
+	LineEnd uint32
+	// Local variables.
+	Locals []*jsonVar
+}
+
+// jsonOverlay is the JSON representation of the declarations of an overlay.
+type jsonOverlay struct {
+	// Overlay ID; zero for the default binary.
+	ID uint32
+	// Base address at which the overlay is loaded.
+	Addr uint32
+	// Overlay length in bytes.
+	Length uint32
+	// Function declarations.
+	Funcs []*jsonFunc
+	// Global variable declarations.
+	Vars []*jsonVar
+	// Static variables of function scope.
+	Statics []*jsonVar
+	// Labels of function scope.
+	Labels []*Symbol
+	// Symbols.
+	Symbols []*Symbol
+	// Source file line numbers.
+	Lines []*Line
+}
+
+// WriteJSON writes the JSON representation of the types and declarations
+// recorded by the parser to w. Types are referred to by tag or typedef name,
+// and defined in order of dependency.
+func (p *Parser) WriteJSON(w io.Writer) error {
+	model := &jsonModel{}
+	if def, ok := p.Types["bool"].(*c.VarDecl); ok {
+		model.Types = append(model.Types, jsonTypedef(def))
+	}
+	for _, t := range p.OrderTypes().Defs {
+		switch t := t.(type) {
+		case *c.StructType:
+			def := &jsonTypeDef{
+				Kind:   "struct",
+				Name:   t.Tag,
+				Size:   t.Size,
+				Fields: jsonFields(t.Fields),
+			}
+			model.Types = append(model.Types, def)
+		case *c.UnionType:
+			def := &jsonTypeDef{
+				Kind:   "union",
+				Name:   t.Tag,
+				Size:   t.Size,
+				Fields: jsonFields(t.Fields),
+			}
+			model.Types = append(model.Types, def)
+		case *c.EnumType:
+			def := &jsonTypeDef{
+				Kind:    "enum",
+				Name:    t.Tag,
+				Members: t.Members,
+			}
+			model.Types = append(model.Types, def)
+		case *c.VarDecl:
+			model.Types = append(model.Types, jsonTypedef(t))
+		}
+	}
+	overlays := append([]*Overlay{p.Overlay}, p.Overlays...)
+	for _, overlay := range overlays {
+		o := &jsonOverlay{
+			ID:      overlay.ID,
+			Addr:    overlay.Addr,
+			Length:  overlay.Length,
+			Funcs:   make([]*jsonFunc, 0, len(overlay.Funcs)),
+			Vars:    make([]*jsonVar, 0, len(overlay.Vars)),
+			Statics: make([]*jsonVar, 0, len(overlay.Statics)),
+			Labels:  overlay.Labels,
+			Symbols: overlay.Symbols,
+			Lines:   overlay.Lines,
+		}
+		for _, f := range overlay.Funcs {
+			o.Funcs = append(o.Funcs, jsonFuncDecl(f))
+		}
+		for _, v := range overlay.Vars {
+			o.Vars = append(o.Vars, jsonVarDecl(v))
+		}
+		for _, v := range overlay.Statics {
+			o.Statics = append(o.Statics, jsonVarDecl(v))
+		}
+		model.Overlays = append(model.Overlays, o)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(model); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// jsonTypedef returns the JSON representation of the given typedef.
+func jsonTypedef(def *c.VarDecl) *jsonTypeDef {
+	return &jsonTypeDef{
+		Kind: "typedef",
+		Name: def.Name,
+		Type: jsonTypeOf(def.Type),
+	}
+}
+
+// jsonFields returns the JSON representation of the given fields.
+func jsonFields(fields []c.Field) []*jsonField {
+	var fs []*jsonField
+	for _, field := range fields {
+		f := &jsonField{
+			Name:      field.Name,
+			Offset:    field.Offset,
+			BitOffset: field.BitOffset,
+			BitWidth:  field.BitWidth,
+			Type:      jsonTypeOf(field.Type),
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// jsonTypeOf returns the JSON representation of the given type.
+func jsonTypeOf(t c.Type) *jsonType {
+	switch t := t.(type) {
+	case c.BaseType:
+		return &jsonType{Kind: "base", Name: t.String()}
+	case *c.StructType:
+		return &jsonType{Kind: "struct", Name: t.Tag}
+	case *c.UnionType:
+		return &jsonType{Kind: "union", Name: t.Tag}
+	case *c.EnumType:
+		return &jsonType{Kind: "enum", Name: t.Tag}
+	case *c.TypedefType:
+		return &jsonType{Kind: "typedef", Name: t.Typedef.Name}
+	case *c.PointerType:
+		return &jsonType{Kind: "pointer", Elem: jsonTypeOf(t.Elem)}
+	case *c.ArrayType:
+		return &jsonType{Kind: "array", Len: t.Len, Elem: jsonTypeOf(t.Elem)}
+	case *c.FuncType:
+		jt := &jsonType{
+			Kind:     "func",
+			RetType:  jsonTypeOf(t.RetType),
+			Variadic: t.Variadic,
+		}
+		for _, param := range t.Params {
+			jt.Params = append(jt.Params, jsonVarDecl(param))
+		}
+		return jt
+	}
+	panic(fmt.Errorf("support for type %T not yet implemented", t))
+}
+
+// jsonVarDecl returns the JSON representation of the given variable
+// declaration.
+func jsonVarDecl(v *c.VarDecl) *jsonVar {
+	jv := &jsonVar{
+		Name: v.Name,
+		Addr: v.Addr,
+		Size: v.Size,
+		Type: jsonTypeOf(v.Type),
+	}
+	if v.Class != 0 {
+		jv.Class = v.Class.String()
+	}
+	return jv
+}
+
+// jsonFuncDecl returns the JSON representation of the given function
+// declaration.
+func jsonFuncDecl(f *c.FuncDecl) *jsonFunc {
+	jf := &jsonFunc{
+		Name:      f.Name,
+		Path:      f.Path,
+		Addr:      f.Addr,
+		AddrEnd:   f.AddrEnd,
+		Size:      f.Size,
+		LineStart: f.LineStart,
+		LineEnd:   f.LineEnd,
+		Type:      jsonTypeOf(f.Type),
+		Frame:     f.Frame,
+		Blocks:    make([]*jsonBlock, 0, len(f.Blocks)),
+	}
+	for _, block := range f.Blocks {
+		b := &jsonBlock{
+			LineStart: block.LineStart,
+			LineEnd:   block.LineEnd,
+			Locals:    make([]*jsonVar, 0, len(block.Locals)),
+		}
+		for _, local := range block.Locals {
+			b.Locals = append(b.Locals, jsonVarDecl(local))
+		}
+		jf.Blocks = append(jf.Blocks, b)
+	}
+	return jf
+}
@@ -0,0 +1,565 @@
+package csym
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mefistotelis/psx_mnd_sym/csym/c"
+	"github.com/pkg/errors"
+	"github.com/rickypai/natsort"
+)
+
+// MergeOptions specifies how the declarations of parsers are merged.
+type MergeOptions struct {
+	// Ignore differences in address between declarations.
+	SkipAddrDiff bool
+	// Ignore differences in line numbers between function declarations.
+	SkipLineDiff bool
+}
+
+// Merge returns a parser holding the types and declarations of the given
+// parsers, with duplicates kept once. Types are compared by structure; types
+// with the same tag but different structure are kept under unique tags. The
+// parsers are left unmodified.
+//
+// The input parsers defining each merged type and declaration are recorded in
+// Origins of the merged parser. Variables and functions are merged within the
+// same overlay only. Symbols and line numbers are not merged; the
+// declarations, symbols and line numbers of each input are kept in Inputs of
+// the merged parser.
+func Merge(parsers []*Parser, opts MergeOptions) (*Parser, error) {
+	if len(parsers) == 0 {
+		return nil, errors.New("no parsers to merge")
+	}
+	m := &merger{
+		dst:    NewParser(parsers[0].opts),
+		opts:   opts,
+		defs:   make(map[int]c.Type),
+		decls:  make(map[string]interface{}),
+		srcs:   make(map[c.Type]c.Type),
+		fields: make(map[c.Type][]c.Field),
+	}
+	m.dst.Origins = make(map[interface{}][]int)
+	m.dst.Inputs = make([]*Parser, len(parsers))
+	// Bool used for NULL type.
+	boolDef := &c.VarDecl{
+		Class: c.Typedef,
+		Var: c.Var{
+			Type: c.Int,
+			Name: "bool",
+		},
+	}
+	m.dst.Types["bool"] = boolDef
+	var types []c.Type
+	for _, p := range parsers {
+		types = append(types, parserTypes(p)...)
+	}
+	classes := typeClasses(types)
+	for i, p := range parsers {
+		typeMap := m.mergeTypes(i, p, classes)
+		if def, ok := p.Types["bool"]; ok {
+			typeMap[def] = boolDef
+		}
+		m.typeMaps = append(m.typeMaps, typeMap)
+	}
+	// Fill in the types once all have been mapped, as they may refer to each
+	// other.
+	for _, typeMap := range m.typeMaps {
+		m.fillTypes(typeMap)
+	}
+	for i, p := range parsers {
+		m.mergeDecls(i, p, m.typeMaps[i])
+	}
+	m.sort()
+	return m.dst, nil
+}
+
+// merger tracks the state of a merge of parsers.
+type merger struct {
+	// Merged parser.
+	dst *Parser
+	// Merge options.
+	opts MergeOptions
+	// defs maps from class of structurally equal types to merged type
+	// definition.
+	defs map[int]c.Type
+	// decls maps from overlay ID and structural key to merged declaration.
+	// Declarations are merged per overlay, as overlays share an address range;
+	// equal declarations of different overlays are kept in each.
+	decls map[string]interface{}
+	// srcs maps from merged type definition to the input type it was cloned
+	// from.
+	srcs map[c.Type]c.Type
+	// fields holds the fields of merged structs and unions, before the types
+	// are mapped.
+	fields map[c.Type][]c.Field
+	// Type maps of input parsers, from input type to merged type.
+	typeMaps []map[c.Type]c.Type
+	// Merged overlays by ID.
+	overlays map[uint32]*Overlay
+}
+
+// mergeTypes adds the type definitions of the input parser not yet present,
+// and returns the map from input types to merged types.
+func (m *merger) mergeTypes(i int, p *Parser, classes map[c.Type]int) map[c.Type]c.Type {
+	typeMap := make(map[c.Type]c.Type)
+	for _, t := range parserTypes(p) {
+		class := classes[t]
+		if dup, ok := m.defs[class]; ok {
+			typeMap[t] = dup
+			m.addOrigin(dup, i)
+			continue
+		}
+		u := m.cloneDef(t)
+		m.defs[class] = u
+		m.srcs[u] = t
+		typeMap[t] = u
+		m.addOrigin(u, i)
+	}
+	return typeMap
+}
+
+// parserTypes returns the type definitions of the parser.
+func parserTypes(p *Parser) []c.Type {
+	var types []c.Type
+	for _, t := range p.Enums {
+		types = append(types, t)
+	}
+	for _, t := range p.Structs {
+		types = append(types, t)
+	}
+	for _, t := range p.Unions {
+		types = append(types, t)
+	}
+	return append(types, p.Typedefs...)
+}
+
+// cloneDef returns a shallow copy of the given type definition, added to the
+// merged parser under a unique tag.
+func (m *merger) cloneDef(t c.Type) c.Type {
+	dst := m.dst
+	switch t := t.(type) {
+	case *c.StructType:
+		u := &c.StructType{
+			Size: t.Size,
+			Tag:  t.Tag,
+		}
+		u.Tag = UniqueStructTag(dst.StructTags, u)
+		m.fields[u] = t.Fields
+		return dst.AddStruct(u)
+	case *c.UnionType:
+		u := &c.UnionType{
+			Size: t.Size,
+			Tag:  t.Tag,
+		}
+		u.Tag = UniqueUnionTag(dst.UnionTags, u)
+		m.fields[u] = t.Fields
+		return dst.AddUnion(u)
+	case *c.EnumType:
+		u := &c.EnumType{
+			Tag: t.Tag,
+		}
+		for _, member := range t.Members {
+			v := *member
+			u.Members = append(u.Members, &v)
+		}
+		u.Tag = UniqueEnumTag(dst.EnumTags, u)
+		return dst.AddEnum(u)
+	case *c.VarDecl:
+		u := &c.VarDecl{
+			Addr:  t.Addr,
+			Size:  t.Size,
+			Class: t.Class,
+			Var:   t.Var,
+		}
+		// Rename typedefs with the same name but different definitions.
+		name := u.Name
+		for k := 1; dst.Types[u.Name] != nil; k++ {
+			u.Name = UniqueTag(name, "t", k)
+		}
+		dst.Types[u.Name] = u
+		dst.Typedefs = append(dst.Typedefs, u)
+		return u
+	default:
+		panic(fmt.Errorf("support for type definition %T not yet implemented", t))
+	}
+}
+
+// fillTypes maps the types used by the merged type definitions cloned from the
+// input parser of the given type map.
+func (m *merger) fillTypes(typeMap map[c.Type]c.Type) {
+	for src, u := range typeMap {
+		if m.srcs[u] != src {
+			continue
+		}
+		switch u := u.(type) {
+		case *c.StructType:
+			u.Fields = cloneFields(m.fields[u], typeMap)
+		case *c.UnionType:
+			u.Fields = cloneFields(m.fields[u], typeMap)
+		case *c.VarDecl:
+			u.Type = cloneType(u.Type, typeMap)
+		}
+	}
+}
+
+// mergeDecls adds the declarations of the input parser not yet present.
+func (m *merger) mergeDecls(i int, p *Parser, typeMap map[c.Type]c.Type) {
+	if m.overlays == nil {
+		m.overlays = make(map[uint32]*Overlay)
+		m.overlays[m.dst.Overlay.ID] = m.dst.Overlay
+	}
+	// Declarations of the input using the merged types.
+	in := NewParser(p.opts)
+	m.dst.Inputs[i] = in
+	overlays := append([]*Overlay{p.Overlay}, p.Overlays...)
+	for _, overlay := range overlays {
+		inOverlay := in.Overlay
+		if overlay != p.Overlay {
+			inOverlay = &Overlay{
+				varNames:  make(map[string][]*c.VarDecl),
+				funcNames: make(map[string][]*c.FuncDecl),
+			}
+			in.Overlays = append(in.Overlays, inOverlay)
+			in.overlayIDs[overlay.ID] = inOverlay
+		}
+		inOverlay.Addr = overlay.Addr
+		inOverlay.ID = overlay.ID
+		inOverlay.Length = overlay.Length
+		for _, s := range overlay.Symbols {
+			u := *s
+			inOverlay.Symbols = append(inOverlay.Symbols, &u)
+		}
+		for _, line := range overlay.Lines {
+			u := *line
+			inOverlay.Lines = append(inOverlay.Lines, &u)
+		}
+		for _, v := range overlay.Statics {
+			inOverlay.Statics = append(inOverlay.Statics, cloneVarDecl(v, typeMap))
+		}
+		for _, l := range overlay.Labels {
+			u := *l
+			inOverlay.Labels = append(inOverlay.Labels, &u)
+		}
+		dstOverlay, ok := m.overlays[overlay.ID]
+		if !ok {
+			dstOverlay = &Overlay{
+				Addr:      overlay.Addr,
+				ID:        overlay.ID,
+				Length:    overlay.Length,
+				varNames:  make(map[string][]*c.VarDecl),
+				funcNames: make(map[string][]*c.FuncDecl),
+			}
+			m.overlays[overlay.ID] = dstOverlay
+			m.dst.Overlays = append(m.dst.Overlays, dstOverlay)
+			m.dst.overlayIDs[overlay.ID] = dstOverlay
+		}
+		for _, v := range overlay.Vars {
+			inVar := cloneVarDecl(v, typeMap)
+			inOverlay.Vars = append(inOverlay.Vars, inVar)
+			inOverlay.varNames[inVar.Name] = append(inOverlay.varNames[inVar.Name], inVar)
+			u := cloneVarDecl(v, typeMap)
+			key := fmt.Sprintf("%x:%s", overlay.ID, m.varKey(u))
+			if dup, ok := m.decls[key]; ok {
+				m.addOrigin(dup, i)
+				continue
+			}
+			m.decls[key] = u
+			m.addOrigin(u, i)
+			dstOverlay.Vars = append(dstOverlay.Vars, u)
+			dstOverlay.varNames[u.Name] = append(dstOverlay.varNames[u.Name], u)
+		}
+		for _, f := range overlay.Funcs {
+			inFunc := cloneFuncDecl(f, typeMap)
+			inOverlay.Funcs = append(inOverlay.Funcs, inFunc)
+			inOverlay.funcNames[inFunc.Name] = append(inOverlay.funcNames[inFunc.Name], inFunc)
+			u := cloneFuncDecl(f, typeMap)
+			key := fmt.Sprintf("%x:%s", overlay.ID, m.funcKey(u))
+			if dup, ok := m.decls[key]; ok {
+				m.addOrigin(dup, i)
+				continue
+			}
+			m.decls[key] = u
+			m.addOrigin(u, i)
+			dstOverlay.Funcs = append(dstOverlay.Funcs, u)
+			dstOverlay.funcNames[u.Name] = append(dstOverlay.funcNames[u.Name], u)
+		}
+	}
+}
+
+// sort sorts the merged typedefs and declarations by name.
+func (m *merger) sort() {
+	dst := m.dst
+	sort.SliceStable(dst.Typedefs, func(i, j int) bool {
+		ti := dst.Typedefs[i].(*c.VarDecl)
+		tj := dst.Typedefs[j].(*c.VarDecl)
+		return natsort.Less(ti.Name, tj.Name)
+	})
+	overlays := append([]*Overlay{dst.Overlay}, dst.Overlays...)
+	for _, overlay := range overlays {
+		vars := overlay.Vars
+		sort.SliceStable(vars, func(i, j int) bool {
+			return natsort.Less(vars[i].Name, vars[j].Name)
+		})
+		funcs := overlay.Funcs
+		sort.SliceStable(funcs, func(i, j int) bool {
+			return natsort.Less(funcs[i].Name, funcs[j].Name)
+		})
+	}
+}
+
+// addOrigin records the input parser as origin of the merged type or
+// declaration.
+func (m *merger) addOrigin(v interface{}, i int) {
+	origins := m.dst.Origins[v]
+	if n := len(origins); n > 0 && origins[n-1] == i {
+		return
+	}
+	m.dst.Origins[v] = append(origins, i)
+}
+
+// varKey returns a key identifying the structure of the given merged variable
+// declaration.
+func (m *merger) varKey(v *c.VarDecl) string {
+	addr := v.Addr
+	if m.opts.SkipAddrDiff && v.Class != c.Register && v.Class != c.Auto {
+		addr = 0
+	}
+	return fmt.Sprintf("%v %s %s 0x%X 0x%X", v.Class, v.Name, typeKey(v.Type, exactTag, nil), addr, v.Size)
+}
+
+// funcKey returns a key identifying the structure of the given merged function
+// declaration.
+func (m *merger) funcKey(f *c.FuncDecl) string {
+	buf := &strings.Builder{}
+	addr, size := f.Addr, f.Size
+	if m.opts.SkipAddrDiff {
+		addr = 0
+	}
+	fmt.Fprintf(buf, "%s %s 0x%X 0x%X", f.Name, typeKey(f.Type, exactTag, nil), addr, size)
+	if !m.opts.SkipLineDiff {
+		fmt.Fprintf(buf, " %s:%d-%d", f.Path, f.LineStart, f.LineEnd)
+	}
+	for _, block := range f.Blocks {
+		buf.WriteString(" {")
+		for _, local := range block.Locals {
+			fmt.Fprintf(buf, " %s;", m.varKey(local))
+		}
+		buf.WriteString(" }")
+	}
+	return buf.String()
+}
+
+// ### [ Helper functions ] ####################################################
+
+// typeClasses partitions the given type definitions into classes of types
+// equal in structure, and returns the class of each type. Types are equal if
+// their definitions are equal, and so are the types they refer to.
+func typeClasses(types []c.Type) map[c.Type]int {
+	keys := make([]string, len(types))
+	refs := make([][]c.Type, len(types))
+	for i, t := range types {
+		keys[i], refs[i] = defKey(t)
+	}
+	// Refine the classes of types until stable, starting with classes of types
+	// with equal definitions.
+	var classes map[c.Type]int
+	n := 0
+	for {
+		ids := make(map[string]int)
+		next := make(map[c.Type]int, len(types))
+		for i, t := range types {
+			buf := &strings.Builder{}
+			buf.WriteString(keys[i])
+			for _, ref := range refs[i] {
+				if id, ok := classes[ref]; ok {
+					fmt.Fprintf(buf, " #%d", id)
+				} else {
+					buf.WriteString(" #?")
+				}
+			}
+			key := buf.String()
+			id, ok := ids[key]
+			if !ok {
+				id = len(ids)
+				ids[key] = id
+			}
+			next[t] = id
+		}
+		classes = next
+		if len(ids) == n {
+			return classes
+		}
+		n = len(ids)
+	}
+}
+
+// defKey returns a key identifying the definition of the given type of an
+// input parser, and the type definitions it refers to. Fake tags and suffixes
+// added to make tags unique are ignored.
+func defKey(t c.Type) (string, []c.Type) {
+	var refs []c.Type
+	buf := &strings.Builder{}
+	switch t := t.(type) {
+	case *c.StructType:
+		fmt.Fprintf(buf, "struct %s 0x%X {", baseTag(t.Tag), t.Size)
+		writeFieldsKey(buf, t.Fields, &refs)
+		buf.WriteString("}")
+	case *c.UnionType:
+		fmt.Fprintf(buf, "union %s 0x%X {", baseTag(t.Tag), t.Size)
+		writeFieldsKey(buf, t.Fields, &refs)
+		buf.WriteString("}")
+	case *c.EnumType:
+		fmt.Fprintf(buf, "enum %s {", baseTag(t.Tag))
+		for _, member := range t.Members {
+			fmt.Fprintf(buf, " %s=%d", member.Name, member.Value)
+		}
+		buf.WriteString(" }")
+	case *c.VarDecl:
+		fmt.Fprintf(buf, "typedef %s %s", t.Name, typeKey(t.Type, baseTag, &refs))
+	}
+	return buf.String(), refs
+}
+
+// writeFieldsKey writes the structural key of the given fields to buf.
+func writeFieldsKey(buf *strings.Builder, fields []c.Field, refs *[]c.Type) {
+	for _, field := range fields {
+		fmt.Fprintf(buf, " %s %s 0x%X 0x%X", field.Name, typeKey(field.Type, baseTag, refs), field.Offset, field.Size)
+		if field.BitWidth > 0 {
+			fmt.Fprintf(buf, " %d:%d", field.BitOffset, field.BitWidth)
+		}
+		buf.WriteString(";")
+	}
+}
+
+// typeKey returns a key identifying the given type. Type definitions are
+// identified by tag or name, as returned by tag, and appended to refs if
+// non-nil.
+func typeKey(t c.Type, tag func(string) string, refs *[]c.Type) string {
+	ref := func(t c.Type) {
+		if refs != nil {
+			*refs = append(*refs, t)
+		}
+	}
+	switch t := t.(type) {
+	case *c.StructType:
+		ref(t)
+		return fmt.Sprintf("struct %s", tag(t.Tag))
+	case *c.UnionType:
+		ref(t)
+		return fmt.Sprintf("union %s", tag(t.Tag))
+	case *c.EnumType:
+		ref(t)
+		return fmt.Sprintf("enum %s", tag(t.Tag))
+	case *c.TypedefType:
+		ref(t.Typedef)
+		return fmt.Sprintf("typedef %s", t.Typedef.Name)
+	case *c.VarDecl:
+		ref(t)
+		return fmt.Sprintf("typedef %s", t.Name)
+	case *c.PointerType:
+		return typeKey(t.Elem, tag, refs) + "*"
+	case *c.ArrayType:
+		return fmt.Sprintf("%s[%d]", typeKey(t.Elem, tag, refs), t.Len)
+	case *c.FuncType:
+		var params []string
+		for _, param := range t.Params {
+			params = append(params, typeKey(param.Type, tag, refs))
+		}
+		if t.Variadic {
+			params = append(params, "...")
+		}
+		return fmt.Sprintf("%s(%s)", typeKey(t.RetType, tag, refs), strings.Join(params, ","))
+	default:
+		return t.String()
+	}
+}
+
+// baseTag returns the given tag with fake tags and suffixes added to make tags
+// unique removed.
+func baseTag(tag string) string {
+	if c.IsFakeTag(tag) {
+		return ""
+	}
+	if i := strings.LastIndex(tag, "_duplicate_"); i > 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// exactTag returns the given tag unchanged.
+func exactTag(tag string) string {
+	return tag
+}
+
+// cloneType returns a copy of the given type, with type definitions replaced
+// as specified by typeMap.
+func cloneType(t c.Type, typeMap map[c.Type]c.Type) c.Type {
+	switch t := t.(type) {
+	case *c.StructType, *c.UnionType, *c.EnumType, *c.VarDecl:
+		if u, ok := typeMap[t]; ok {
+			return u
+		}
+		return t
+	case *c.TypedefType:
+		if u, ok := typeMap[t.Typedef].(*c.VarDecl); ok {
+			return &c.TypedefType{Typedef: u}
+		}
+		return &c.TypedefType{Typedef: t.Typedef}
+	case *c.PointerType:
+		return &c.PointerType{Elem: cloneType(t.Elem, typeMap)}
+	case *c.ArrayType:
+		return &c.ArrayType{Elem: cloneType(t.Elem, typeMap), Len: t.Len}
+	case *c.FuncType:
+		u := &c.FuncType{
+			RetType:  cloneType(t.RetType, typeMap),
+			Variadic: t.Variadic,
+		}
+		for _, param := range t.Params {
+			u.Params = append(u.Params, cloneVarDecl(param, typeMap))
+		}
+		return u
+	default:
+		return t
+	}
+}
+
+// cloneFields returns a copy of the given fields, with type definitions
+// replaced as specified by typeMap.
+func cloneFields(fields []c.Field, typeMap map[c.Type]c.Type) []c.Field {
+	var us []c.Field
+	for _, field := range fields {
+		u := field
+		u.Type = cloneType(field.Type, typeMap)
+		us = append(us, u)
+	}
+	return us
+}
+
+// cloneVarDecl returns a copy of the given variable declaration, with type
+// definitions replaced as specified by typeMap.
+func cloneVarDecl(v *c.VarDecl, typeMap map[c.Type]c.Type) *c.VarDecl {
+	u := *v
+	u.Type = cloneType(v.Type, typeMap)
+	return &u
+}
+
+// cloneFuncDecl returns a copy of the given function declaration, with type
+// definitions replaced as specified by typeMap.
+func cloneFuncDecl(f *c.FuncDecl, typeMap map[c.Type]c.Type) *c.FuncDecl {
+	u := *f
+	u.Type = cloneType(f.Type, typeMap)
+	u.Blocks = nil
+	for _, block := range f.Blocks {
+		b := &c.Block{
+			LineStart: block.LineStart,
+			LineEnd:   block.LineEnd,
+		}
+		for _, local := range block.Locals {
+			b.Locals = append(b.Locals, cloneVarDecl(local, typeMap))
+		}
+		u.Blocks = append(u.Blocks, b)
+	}
+	return &u
+}
@@ -0,0 +1,201 @@
+package csym
+
+import (
+	"strings"
+
+	"github.com/mefistotelis/psx_mnd_sym/csym/c"
+)
+
+// TypeOrder is an ordering of the type definitions of a parser, in which each
+// type is defined before being used by the definitions which follow.
+type TypeOrder struct {
+	// Structs and unions referenced through pointers before being defined, to
+	// be declared ahead of the type definitions.
+	Forward []c.Type
+	// Enums, structs, unions and typedefs in order of definition.
+	Defs []c.Type
+	// Dependency cycles; types which require the definition of each other.
+	Cycles [][]c.Type
+}
+
+// OrderTypes returns the type definitions recorded by the parser ordered by
+// dependency. Types keep their order of occurrence in the SYM file where
+// possible, with enums first.
+func (p *Parser) OrderTypes() *TypeOrder {
+	o := &typeOrderer{
+		order: &TypeOrder{},
+		nodes: make(map[c.Type]*typeNode),
+	}
+	var types []c.Type
+	for _, t := range p.Enums {
+		types = append(types, t)
+	}
+	for _, t := range p.Structs {
+		types = append(types, t)
+	}
+	for _, t := range p.Unions {
+		types = append(types, t)
+	}
+	types = append(types, p.Typedefs...)
+	for _, t := range types {
+		o.nodes[t] = &typeNode{}
+	}
+	for _, t := range types {
+		o.visit(t)
+	}
+	// Add forward declarations for types used through pointers before being
+	// defined.
+	defined := make(map[c.Type]bool)
+	forwarded := make(map[c.Type]bool)
+	for _, t := range o.order.Defs {
+		for _, dep := range o.nodes[t].soft {
+			if dep == t || defined[dep] || forwarded[dep] {
+				continue
+			}
+			forwarded[dep] = true
+			o.order.Forward = append(o.order.Forward, dep)
+		}
+		defined[t] = true
+	}
+	return o.order
+}
+
+// CycleString returns a string representation of the dependency cycle.
+func CycleString(cycle []c.Type) string {
+	var names []string
+	for _, t := range cycle {
+		names = append(names, typeName(t))
+	}
+	if len(cycle) > 0 {
+		names = append(names, typeName(cycle[0]))
+	}
+	return strings.Join(names, " -> ")
+}
+
+// typeOrderer tracks the state of a dependency ordering of types.
+type typeOrderer struct {
+	// Output type ordering.
+	order *TypeOrder
+	// nodes maps from type definitions to ordering information.
+	nodes map[c.Type]*typeNode
+	// Types being visited.
+	stack []c.Type
+}
+
+// typeNode holds the ordering information of a type definition.
+type typeNode struct {
+	// Visiting state; 0 if unvisited, 1 if being visited, and 2 once ordered.
+	state int
+	// Types used through pointers, which may be declared rather than defined
+	// before use.
+	soft []c.Type
+}
+
+// visit orders the given type after the types it depends on.
+func (o *typeOrderer) visit(t c.Type) {
+	node := o.nodes[t]
+	switch node.state {
+	case 1:
+		// Record the dependency cycle, starting at the type.
+		for i := len(o.stack) - 1; i >= 0; i-- {
+			if o.stack[i] == t {
+				cycle := append([]c.Type(nil), o.stack[i:]...)
+				o.order.Cycles = append(o.order.Cycles, cycle)
+				break
+			}
+		}
+		return
+	case 2:
+		return
+	}
+	node.state = 1
+	o.stack = append(o.stack, t)
+	var hard []c.Type
+	add := func(dep c.Type, ptr bool) {
+		if _, ok := o.nodes[dep]; !ok {
+			return
+		}
+		if ptr {
+			node.soft = append(node.soft, dep)
+		} else {
+			hard = append(hard, dep)
+		}
+	}
+	switch t := t.(type) {
+	case *c.StructType:
+		for _, field := range t.Fields {
+			typeDeps(field.Type, false, add)
+		}
+	case *c.UnionType:
+		for _, field := range t.Fields {
+			typeDeps(field.Type, false, add)
+		}
+	case *c.VarDecl:
+		// A typedef of a struct or union does not require its definition.
+		typeDeps(t.Type, isTaggedType(t.Type), add)
+	}
+	for _, dep := range hard {
+		o.visit(dep)
+	}
+	o.stack = o.stack[:len(o.stack)-1]
+	node.state = 2
+	o.order.Defs = append(o.order.Defs, t)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// typeDeps calls add for each type definition used by the given type. The ptr
+// argument reports whether the type is used through a pointer.
+func typeDeps(t c.Type, ptr bool, add func(dep c.Type, ptr bool)) {
+	switch t := t.(type) {
+	case *c.StructType:
+		add(t, ptr)
+	case *c.UnionType:
+		if c.IsFakeTag(t.Tag) {
+			// Unions with fake tags are defined inline.
+			for _, field := range t.Fields {
+				typeDeps(field.Type, false, add)
+			}
+			return
+		}
+		add(t, ptr)
+	case *c.EnumType:
+		add(t, false)
+	case *c.TypedefType:
+		// Typedef names must be defined before use, and so must the underlying
+		// type when used by value.
+		add(t.Typedef, false)
+		if !ptr {
+			typeDeps(t.Typedef.Type, false, add)
+		}
+	case *c.PointerType:
+		typeDeps(t.Elem, true, add)
+	case *c.ArrayType:
+		typeDeps(t.Elem, ptr, add)
+	case *c.FuncType:
+		typeDeps(t.RetType, true, add)
+		for _, param := range t.Params {
+			typeDeps(param.Type, true, add)
+		}
+	}
+}
+
+// isTaggedType reports whether the given type is a struct or union declared
+// by tag.
+func isTaggedType(t c.Type) bool {
+	switch t := t.(type) {
+	case *c.StructType:
+		return true
+	case *c.UnionType:
+		return !c.IsFakeTag(t.Tag)
+	}
+	return false
+}
+
+// typeName returns the name of the given type definition.
+func typeName(t c.Type) string {
+	if def, ok := t.(*c.VarDecl); ok {
+		return def.Name
+	}
+	return t.String()
+}
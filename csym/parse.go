@@ -2,6 +2,9 @@
 package csym
 
 import (
+	"encoding/binary"
+	"fmt"
+
 	"github.com/mefistotelis/psx_mnd_sym"
 	"github.com/mefistotelis/psx_mnd_sym/csym/c"
 )
@@ -28,6 +31,8 @@ type Parser struct {
 	Typedefs []c.Type
 	// Tracks unique enum member names.
 	enumMembers map[string]bool
+	// typedefNames maps from types to the typedef names used in their place.
+	typedefNames map[c.Type]c.Type
 
 	// Declarations.
 	*Overlay // default binary
@@ -39,6 +44,20 @@ type Parser struct {
 
 	// Current overlay.
 	curOverlay *Overlay
+	// Symbol being parsed.
+	curSym *sym.Symbol
+	// offsets maps from symbol to its offset within the symbol file.
+	offsets map[*sym.Symbol]int64
+
+	// Problems found while parsing in lenient mode.
+	Diags []*sym.Diagnostic
+
+	// Origins maps from the types and declarations of a merged parser to the
+	// indices of the input parsers defining them; nil if not merged.
+	Origins map[interface{}][]int
+	// Inputs holds the declarations of the input parsers of a merged parser,
+	// using the merged types; nil if not merged.
+	Inputs []*Parser
 
 	// Option switches.
 	opts *sym.Options
@@ -63,6 +82,39 @@ func NewParser(opts *sym.Options) *Parser {
 	}
 }
 
+// fail reports a problem with the symbol being parsed. In lenient mode the
+// problem is recorded as a diagnostic and parsing continues; otherwise the
+// parser panics.
+func (p *Parser) fail(format string, args ...interface{}) {
+	err := fmt.Errorf(format, args...)
+	if !p.opts.Lenient {
+		panic(err)
+	}
+	diag := &sym.Diagnostic{
+		Msg: err.Error(),
+	}
+	if p.curSym != nil {
+		diag.Offset = p.offsets[p.curSym]
+		diag.Kind = p.curSym.Hdr.Kind
+	}
+	p.Diags = append(p.Diags, diag)
+}
+
+// initOffsets records the file offsets of the given symbols, for use in
+// diagnostics. The symbols are assumed to be the complete list of symbols of a
+// file.
+func (p *Parser) initOffsets(syms []*sym.Symbol) {
+	if !p.opts.Lenient {
+		return
+	}
+	p.offsets = make(map[*sym.Symbol]int64, len(syms))
+	offset := int64(binary.Size(sym.FileHeader{}))
+	for _, s := range syms {
+		p.offsets[s] = offset
+		offset += int64(s.Size())
+	}
+}
+
 // An Overlay is an overlay appended to the end of the executable.
 type Overlay struct {
 	// Base address at which the overlay is loaded.
@@ -81,6 +133,13 @@ type Overlay struct {
 	// funcNames maps from function name to function declaration.
 	funcNames map[string][]*c.FuncDecl
 
+	// Static variables of function scope, named after the function and
+	// variable (e.g. func.var).
+	Statics []*c.VarDecl
+	// Labels of function scope, named after the function and label (e.g.
+	// func.label).
+	Labels []*Symbol
+
 	// Symbols.
 	Symbols []*Symbol
 	// Source file line numbers.
@@ -93,6 +152,10 @@ type Symbol struct {
 	Addr uint32
 	// Symbol name.
 	Name string
+	// Symbol kind; code, data or absolute (plain symbols only).
+	Kind SymbolKind `json:",omitempty"`
+	// Symbol binding; global, local or absolute (plain symbols only).
+	Binding SymbolBinding `json:",omitempty"`
 }
 
 // A Line associates a line number in a source file with an address.
@@ -11,13 +11,20 @@ import (
 // ParseDecls parses the symbols into the equivalent C declarations.
 func (p *Parser) ParseDecls(syms []*sym.Symbol) {
 	if p.opts.Verbose { fmt.Printf("Parsing %d symbol tags for declarations...\n", len(syms)) }
+	p.initOffsets(syms)
 	for i := 0; i < len(syms); i++ {
 		s := syms[i]
+		p.curSym = s
 		switch body := s.Body.(type) {
 		case *sym.Name1:
-			p.parseSymbol(s.Hdr.Value, body.Name)
+			p.parseSymbol(s.Hdr.Value, body.Name, BindGlobal)
 		case *sym.Name2:
-			p.parseSymbol(s.Hdr.Value, body.Name)
+			p.parseSymbol(s.Hdr.Value, body.Name, BindGlobal)
+		case *sym.Name5:
+			// Both kinds are local; see sym.Name5.
+			p.parseSymbol(s.Hdr.Value, body.Name, BindLocal)
+		case *sym.Name6:
+			p.parseSymbol(s.Hdr.Value, body.Name, BindLocal)
 		case *sym.SetSLD2:
 			n := p.parseLineNumbers(s.Hdr.Value, body, syms[i+1:])
 			i += n
@@ -36,7 +43,7 @@ func (p *Parser) ParseDecls(syms []*sym.Symbol) {
 			case sym.ClassMOS, sym.ClassSTRTAG, sym.ClassMOU, sym.ClassUNTAG, sym.ClassTPDEF, sym.ClassENTAG, sym.ClassMOE, sym.ClassFIELD, sym.Class103:
 				// nothing to do.
 			default:
-				panic(fmt.Sprintf("support for symbol class %q not yet implemented", body.Class))
+				p.fail("support for symbol class %q not yet implemented", body.Class)
 			}
 		case *sym.Def2:
 			switch body.Class {
@@ -46,29 +53,35 @@ func (p *Parser) ParseDecls(syms []*sym.Symbol) {
 			case sym.ClassMOS, sym.ClassMOU, sym.ClassTPDEF, sym.ClassMOE, sym.ClassFIELD, sym.ClassEOS:
 				// nothing to do.
 			default:
-				panic(fmt.Sprintf("support for symbol class %q not yet implemented", body.Class))
+				p.fail("support for symbol class %q not yet implemented", body.Class)
 			}
 		case *sym.Overlay:
 			p.parseOverlay(s.Hdr.Value, body)
 		case *sym.SetOverlay:
 			overlay, ok := p.overlayIDs[s.Hdr.Value]
 			if !ok {
-				panic(fmt.Errorf("unable to locate overlay with ID %x", s.Hdr.Value))
+				// Keep the current overlay in lenient mode.
+				p.fail("unable to locate overlay with ID %x", s.Hdr.Value)
+				continue
 			}
 			p.curOverlay = overlay
+		case *sym.RawBody:
+			// Symbol of unknown kind, kept by the SYM parser; nothing to do.
 		default:
-			panic(fmt.Sprintf("support for symbol type %T not yet implemented", body))
+			p.fail("support for symbol type %T not yet implemented", body)
 		}
 	}
+	p.classifySymbols()
 	if p.opts.Verbose { fmt.Printf("Created %d functions, %d global variables\n", len(p.curOverlay.Funcs), len(p.curOverlay.Vars)) }
 }
 
 // parseSymbol parses a symbol and its associated address.
-func (p *Parser) parseSymbol(addr uint32, name string) {
+func (p *Parser) parseSymbol(addr uint32, name string, binding SymbolBinding) {
 	// TODO: name = validName(name)?
 	symbol := &Symbol{
-		Addr: addr,
-		Name: name,
+		Addr:    addr,
+		Name:    name,
+		Binding: binding,
 	}
 	p.curOverlay.Symbols = append(p.curOverlay.Symbols, symbol)
 }
@@ -87,6 +100,7 @@ func (p *Parser) parseLineNumbers(addr uint32, body *sym.SetSLD2, syms []*sym.Sy
 	p.curOverlay.Lines = append(p.curOverlay.Lines, line)
 	for n = 0; n < len(syms); n++ {
 		s := syms[n]
+		p.curSym = s
 		switch body := s.Body.(type) {
 		case *sym.IncSLD:
 			curLine.Line++
@@ -136,7 +150,8 @@ func (p *Parser) parseLineNumbers(addr uint32, body *sym.SetSLD2, syms []*sym.Sy
 			return n
 		}
 	}
-	panic("unreachable")
+	p.fail("unterminated line numbers sequence of file %q", curLine.Path)
+	return n
 }
 
 // emptyFunc creates an empty/dummy function declaration when real one is missing.
@@ -165,6 +180,13 @@ func (p *Parser) parseFunc(addr uint32, body *sym.FuncStart, syms []*sym.Symbol)
 		}
 	}
 	f.Path = body.Path
+	f.Frame = &c.Frame{
+		FP:         uint32(body.FP),
+		Size:       body.FSize,
+		RetReg:     uint32(body.RetReg),
+		Mask:       body.Mask,
+		MaskOffset: body.MaskOffset,
+	}
 	// Parse function declaration.
 	f.LineStart = body.Line
 	curLine := Line{
@@ -181,8 +203,10 @@ func (p *Parser) parseFunc(addr uint32, body *sym.FuncStart, syms []*sym.Symbol)
 	var curBlock *c.Block
 	for n = 0; n < len(syms); n++ {
 		s := syms[n]
+		p.curSym = s
 		switch body := s.Body.(type) {
 		case *sym.FuncEnd:
+			f.AddrEnd = s.Hdr.Value
 			f.LineEnd = body.Line
 			return n + 1
 		case *sym.BlockStart:
@@ -202,6 +226,10 @@ func (p *Parser) parseFunc(addr uint32, body *sym.FuncStart, syms []*sym.Symbol)
 			}
 			p.curOverlay.Lines = append(p.curOverlay.Lines, line)
 		case *sym.BlockEnd:
+			if curBlock == nil {
+				p.fail("block end without matching block start in function %q", f.Name)
+				continue
+			}
 			curBlock.LineEnd = body.Line
 			if !blocks.empty() {
 				curBlock = blocks.pop()
@@ -216,35 +244,92 @@ func (p *Parser) parseFunc(addr uint32, body *sym.FuncStart, syms []*sym.Symbol)
 			}
 			p.curOverlay.Lines = append(p.curOverlay.Lines, line)
 		case *sym.Def:
+			if body.Class == sym.ClassLABEL {
+				p.addLabel(f, s.Hdr.Value, body.Name)
+				continue
+			}
 			t := p.parseType(body.Type, nil, "")
 			v := p.parseLocalDecl(s.Hdr.Value, body.Size, body.Class, t, body.Name)
+			if v == nil {
+				continue
+			}
 			if curBlock != nil {
 				addLocal(curBlock, v)
+				if v.Class == c.Static {
+					p.addStatic(f, v)
+				}
 			} else {
-				addParam(funcType, v)
+				addParam(funcType, v, body.Class)
 			}
 		case *sym.Def2:
+			if body.Class == sym.ClassLABEL {
+				p.addLabel(f, s.Hdr.Value, body.Name)
+				continue
+			}
 			t := p.parseType(body.Type, body.Dims, body.Tag)
 			v := p.parseLocalDecl(s.Hdr.Value, body.Size, body.Class, t, body.Name)
+			if v == nil {
+				continue
+			}
 			if curBlock != nil {
 				addLocal(curBlock, v)
+				if v.Class == c.Static {
+					p.addStatic(f, v)
+				}
 			} else {
-				addParam(funcType, v)
+				addParam(funcType, v, body.Class)
 			}
+		case *sym.RawBody:
+			// Symbol of unknown kind, kept by the SYM parser; nothing to do.
 		default:
-			panic(fmt.Errorf("support for symbol type %T not yet implemented", body))
+			p.fail("support for symbol type %T not yet implemented", body)
 		}
 	}
-	panic("unreachable")
+	p.fail("unterminated function %q", f.Name)
+	return n
 }
 
-// parseLocalDecl parses a local declaration symbol.
+// addStatic adds the static local variable of the given function to the
+// addressable variables of the current overlay, named after the function and
+// variable.
+func (p *Parser) addStatic(f *c.FuncDecl, local *c.VarDecl) {
+	v := *local
+	v.Name = f.Name + "." + local.Name
+	for _, static := range p.curOverlay.Statics {
+		if static.Name == v.Name {
+			return
+		}
+	}
+	p.curOverlay.Statics = append(p.curOverlay.Statics, &v)
+}
+
+// addLabel adds the label of the given function to the current overlay, named
+// after the function and label.
+func (p *Parser) addLabel(f *c.FuncDecl, addr uint32, name string) {
+	label := &Symbol{
+		Addr: addr,
+		Name: f.Name + "." + validName(name),
+	}
+	for _, l := range p.curOverlay.Labels {
+		if l.Name == label.Name {
+			return
+		}
+	}
+	p.curOverlay.Labels = append(p.curOverlay.Labels, label)
+}
+
+// parseLocalDecl parses a local declaration symbol. It returns nil if the
+// symbol class is not supported (lenient mode only).
 func (p *Parser) parseLocalDecl(addr, size uint32, class sym.Class, t c.Type, name string) *c.VarDecl {
 	name = validName(name)
+	cls, ok := p.parseClass(class)
+	if !ok {
+		return nil
+	}
 	v := &c.VarDecl{
 		Addr:  addr,
 		Size:  size,
-		Class: parseClass(class),
+		Class: cls,
 		Var: c.Var{
 			Type: t,
 			Name: name,
@@ -276,10 +361,14 @@ func (p *Parser) parseGlobalDecl(addr, size uint32, class sym.Class, t c.Type, n
 		p.curOverlay.funcNames[name] = append(p.curOverlay.funcNames[name], f)
 		return
 	}
+	cls, ok := p.parseClass(class)
+	if !ok {
+		return
+	}
 	v := &c.VarDecl{
 		Addr:  addr,
 		Size:  size,
-		Class: parseClass(class),
+		Class: cls,
 		Var: c.Var{
 			Type: t,
 			Name: name,
@@ -333,27 +422,30 @@ func findFunc(p *Parser, name string, addr uint32) (*c.FuncDecl, *c.FuncType) {
 	return f, funcType
 }
 
-// parseClass parses the symbol class into an equivalent C storage class.
-func parseClass(class sym.Class) c.StorageClass {
+// parseClass parses the symbol class into an equivalent C storage class. The
+// boolean result is false if the symbol class is not supported.
+func (p *Parser) parseClass(class sym.Class) (c.StorageClass, bool) {
 	switch class {
 	case sym.ClassAUTO:
-		return c.Auto
+		return c.Auto, true
 	case sym.ClassEXT:
-		return c.Extern
+		return c.Extern, true
 	case sym.ClassSTAT:
-		return c.Static
+		return c.Static, true
 	case sym.ClassREG:
-		return c.Register
+		return c.Register, true
 	case sym.ClassLABEL:
-		return 0
+		return 0, true
 	case sym.ClassARG:
-		return 0
+		return 0, true
 	case sym.ClassTPDEF:
-		return c.Typedef
+		return c.Typedef, true
 	case sym.ClassREGPARM:
-		return c.Register
+		// Arguments passed in registers; Addr holds the register number.
+		return c.Register, true
 	default:
-		panic(fmt.Errorf("support for symbol class %v not yet implemented", class))
+		p.fail("support for symbol class %v not yet implemented", class)
+		return 0, false
 	}
 }
 
@@ -392,8 +484,12 @@ func addLocal(block *c.Block, local *c.VarDecl) {
 }
 
 // addParam adds the function parameter to the function type if not already
-// present.
-func addParam(t *c.FuncType, param *c.VarDecl) {
+// present. Parameters passed on the stack (ARG symbols) are auto, with Addr
+// holding the frame pointer delta; ARG locals of blocks keep no storage class.
+func addParam(t *c.FuncType, param *c.VarDecl, class sym.Class) {
+	if class == sym.ClassARG {
+		param.Class = c.Auto
+	}
 	for _, p := range t.Params {
 		if p.Name == param.Name {
 			return
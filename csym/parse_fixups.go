@@ -124,6 +124,14 @@ func replaceUsedSubtypesInType(t c.Type, typeRemap map[c.Type]c.Type) {
 		for i := 0; i < len(tp.Fields); i++ {
 			replaceUsedTypesInVar(&tp.Fields[i].Var, typeRemap)
 		}
+	case *c.VarDecl:
+		// Type definition; keep the underlying type if replaced by the name
+		// of the type definition itself.
+		t1, ok := typeRemap[tp.Type]
+		if def, isDef := t1.(*c.TypedefType); ok && !(isDef && def.Typedef == tp) {
+			tp.Type = t1
+		}
+		replaceUsedSubtypesInType(tp.Type, typeRemap)
 	}
 }
 
@@ -141,9 +149,6 @@ func (p *Parser) replaceUsedTypesInStructs(typeRemap map[c.Type]c.Type) {
 		for k := 0; k < len(t.Fields); k++ {
 			replaceUsedTypesInVar(&t.Fields[k].Var, typeRemap)
 		}
-		for k := 0; k < len(t.Methods); k++ {
-			replaceUsedTypesInVar(&t.Methods[k].Var, typeRemap)
-		}
 	}
 }
 
@@ -158,7 +163,8 @@ func (p *Parser) replaceUsedTypesInUnions(typeRemap map[c.Type]c.Type) {
 func (p *Parser) replaceUsedTypesInTypedefs(typeRemap map[c.Type]c.Type) {
 	for i := 0; i < len(p.Typedefs); i++ {
 		t := p.Typedefs[i]
-		// Do not replace the typedef itself, only uses of types within
+		// Do not replace the typedef itself, only uses of types within; e.g.
+		// the struct aliased by the typedef if merged with a duplicate.
 		replaceUsedSubtypesInType(t, typeRemap)
 	}
 }
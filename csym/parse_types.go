@@ -11,11 +11,13 @@ import (
 
 // ParseTypes parses the SYM types into the equivalent C types.
 func (p *Parser) ParseTypes(syms []*sym.Symbol) {
+	p.initOffsets(syms)
 	p.initTaggedTypes(syms)
 	if p.opts.Verbose { fmt.Printf("Parsing %d symbol tags for types...\n", len(syms)) }
 	// Parse symbols.
 	for i := 0; i < len(syms); i++ {
 		s := syms[i]
+		p.curSym = s
 		switch body := s.Body.(type) {
 		case *sym.Def:
 			switch body.Class {
@@ -42,6 +44,7 @@ func (p *Parser) ParseTypes(syms []*sym.Symbol) {
 		// such verification is made when parsing declarations (`parse_decls.go`)
 		}
 	}
+	p.resolveTypedefs()
 	if p.opts.Verbose {
 		fmt.Printf("Created %d structs, %d enums, %d unions, %d types.\n",
 			len(p.Structs), len(p.Enums), len(p.Unions), len(p.Types))
@@ -82,12 +85,14 @@ func (p *Parser) initTaggedTypes(syms []*sym.Symbol) {
 // parseStructTag parses a struct tag sequence of symbols.
 func (p *Parser) parseStructTag(body *sym.Def, syms []*sym.Symbol) (n int) {
 	if base := body.Type.Base(); base != sym.BaseStruct {
-		panic(fmt.Errorf("support for base type %q not yet implemented", base))
+		p.fail("support for base type %q not yet implemented", base)
+		return 0
 	}
 	tag := validName(body.Name)
 	t := findEmptyStruct(p, tag, body.Size)
 	for n = 0; n < len(syms); n++ {
 		s := syms[n]
+		p.curSym = s
 		switch body := s.Body.(type) {
 		case *sym.Def:
 			switch body.Class {
@@ -102,18 +107,9 @@ func (p *Parser) parseStructTag(body *sym.Def, syms []*sym.Symbol) (n int) {
 				}
 				t.Fields = append(t.Fields, field)
 			case sym.ClassFIELD:
-				// TODO: Figure out what FIELD represents. Use method for now.
-				method := c.Field{
-					Offset: s.Hdr.Value,
-					Size:   body.Size,
-					Var: c.Var{
-						Type: p.parseType(body.Type, nil, ""),
-						Name: validName(body.Name),
-					},
-				}
-				t.Methods = append(t.Methods, method)
+				t.Fields = append(t.Fields, p.parseBitfield(s.Hdr.Value, body.Size, body.Type, nil, "", body.Name))
 			default:
-				panic(fmt.Errorf("support for class %q not yet implemented", body.Class))
+				p.fail("support for class %q not yet implemented", body.Class)
 			}
 		case *sym.Def2:
 			switch body.Class {
@@ -127,25 +123,30 @@ func (p *Parser) parseStructTag(body *sym.Def, syms []*sym.Symbol) (n int) {
 					},
 				}
 				t.Fields = append(t.Fields, field)
+			case sym.ClassFIELD:
+				t.Fields = append(t.Fields, p.parseBitfield(s.Hdr.Value, body.Size, body.Type, body.Dims, body.Tag, body.Name))
 			case sym.ClassEOS:
 				return n + 1
 			default:
-				panic(fmt.Errorf("support for class %q not yet implemented", body.Class))
+				p.fail("support for class %q not yet implemented", body.Class)
 			}
 		}
 	}
-	panic("unreachable")
+	p.fail("unterminated struct tag %q", tag)
+	return n
 }
 
 // parseUnionTag parses a union tag sequence of symbols.
 func (p *Parser) parseUnionTag(body *sym.Def, syms []*sym.Symbol) (n int) {
 	if base := body.Type.Base(); base != sym.BaseUnion {
-		panic(fmt.Errorf("support for base type %q not yet implemented", base))
+		p.fail("support for base type %q not yet implemented", base)
+		return 0
 	}
 	tag := validName(body.Name)
 	t := findEmptyUnion(p, tag, body.Size)
 	for n = 0; n < len(syms); n++ {
 		s := syms[n]
+		p.curSym = s
 		switch body := s.Body.(type) {
 		case *sym.Def:
 			switch body.Class {
@@ -159,8 +160,10 @@ func (p *Parser) parseUnionTag(body *sym.Def, syms []*sym.Symbol) (n int) {
 					},
 				}
 				t.Fields = append(t.Fields, field)
+			case sym.ClassFIELD:
+				t.Fields = append(t.Fields, p.parseBitfield(s.Hdr.Value, body.Size, body.Type, nil, "", body.Name))
 			default:
-				panic(fmt.Errorf("support for class %q not yet implemented", body.Class))
+				p.fail("support for class %q not yet implemented", body.Class)
 			}
 		case *sym.Def2:
 			switch body.Class {
@@ -174,25 +177,44 @@ func (p *Parser) parseUnionTag(body *sym.Def, syms []*sym.Symbol) (n int) {
 					},
 				}
 				t.Fields = append(t.Fields, field)
+			case sym.ClassFIELD:
+				t.Fields = append(t.Fields, p.parseBitfield(s.Hdr.Value, body.Size, body.Type, body.Dims, body.Tag, body.Name))
 			case sym.ClassEOS:
 				return n + 1
 			default:
-				panic(fmt.Errorf("support for class %q not yet implemented", body.Class))
+				p.fail("support for class %q not yet implemented", body.Class)
 			}
 		}
 	}
-	panic("unreachable")
+	p.fail("unterminated union tag %q", tag)
+	return n
+}
+
+// parseBitfield parses a bitfield member of a struct or union. The offset and
+// size of bitfields are given in bits.
+func (p *Parser) parseBitfield(offset, size uint32, t sym.Type, dims []uint32, tag, name string) c.Field {
+	return c.Field{
+		Offset:    offset / 8,
+		BitOffset: offset,
+		BitWidth:  size,
+		Var: c.Var{
+			Type: p.parseType(t, dims, tag),
+			Name: validName(name),
+		},
+	}
 }
 
 // parseEnumTag parses an enum tag sequence of symbols.
 func (p *Parser) parseEnumTag(body *sym.Def, syms []*sym.Symbol) (n int) {
 	if base := body.Type.Base(); base != sym.BaseEnum {
-		panic(fmt.Errorf("support for base type %q not yet implemented", base))
+		p.fail("support for base type %q not yet implemented", base)
+		return 0
 	}
 	tag := validName(body.Name)
 	t := findEmptyEnum(p, tag)
 	for n = 0; n < len(syms); n++ {
 		s := syms[n]
+		p.curSym = s
 		switch body := s.Body.(type) {
 		case *sym.Def:
 			switch body.Class {
@@ -205,18 +227,19 @@ func (p *Parser) parseEnumTag(body *sym.Def, syms []*sym.Symbol) (n int) {
 				}
 				t.Members = append(t.Members, member)
 			default:
-				panic(fmt.Errorf("support for class %q not yet implemented", body.Class))
+				p.fail("support for class %q not yet implemented", body.Class)
 			}
 		case *sym.Def2:
 			switch body.Class {
 			case sym.ClassEOS:
 				return n + 1
 			default:
-				panic(fmt.Errorf("support for class %q not yet implemented", body.Class))
+				p.fail("support for class %q not yet implemented", body.Class)
 			}
 		}
 	}
-	panic("unreachable")
+	p.fail("unterminated enum tag %q", tag)
+	return n
 }
 
 // parseTypedef parses a typedef symbol.
@@ -229,10 +252,67 @@ func (p *Parser) parseTypedef(t sym.Type, dims []uint32, tag, name string) {
 			Name: name,
 		},
 	}
+	// Keep the underlying type of typedefs redefining a name.
+	if u, ok := def.Type.(*c.TypedefType); ok && u.Typedef.Name == name {
+		def.Type = u.Typedef.Type
+	}
 	p.Typedefs = append(p.Typedefs, def)
 	p.Types[name] = def
 }
 
+// resolveTypedefs replaces uses of struct, union and enum types by the name of
+// their typedef, if aliased by a single typedef or referred to by typedef name
+// in place of a tag.
+func (p *Parser) resolveTypedefs() {
+	if p.opts.Verbose { fmt.Printf("Resolving typedef names...\n") }
+	// aliases maps from type to the typedefs aliasing it.
+	aliases := make(map[c.Type][]*c.VarDecl)
+	for _, t := range p.Typedefs {
+		def := t.(*c.VarDecl)
+		switch def.Type.(type) {
+		case *c.StructType, *c.UnionType, *c.EnumType:
+			aliases[def.Type] = append(aliases[def.Type], def)
+		}
+	}
+	typeRemap := make(map[c.Type]c.Type)
+	for t, defs := range aliases {
+		if def := pickAlias(t, defs); def != nil {
+			typeRemap[t] = &c.TypedefType{Typedef: def}
+		}
+	}
+	// Remove the empty types created for tags which name typedefs.
+	structRemap := make(map[c.Type]c.Type)
+	for _, t := range p.Structs {
+		if def := p.placeholderTypedef(t, t.Tag, len(t.Fields) == 0 && t.Size == 0); def != nil {
+			typeRemap[t] = &c.TypedefType{Typedef: def}
+			structRemap[t] = nil
+		}
+	}
+	unionRemap := make(map[c.Type]c.Type)
+	for _, t := range p.Unions {
+		if def := p.placeholderTypedef(t, t.Tag, len(t.Fields) == 0 && t.Size == 0); def != nil {
+			typeRemap[t] = &c.TypedefType{Typedef: def}
+			unionRemap[t] = nil
+		}
+	}
+	enumRemap := make(map[c.Type]c.Type)
+	for _, t := range p.Enums {
+		if def := p.placeholderTypedef(t, t.Tag, len(t.Members) == 0); def != nil {
+			typeRemap[t] = &c.TypedefType{Typedef: def}
+			enumRemap[t] = nil
+		}
+	}
+	p.ReplaceUsedTypes(typeRemap)
+	p.ReplaceStructs(structRemap)
+	p.RmNilStructs()
+	p.ReplaceUnions(unionRemap)
+	p.RmNilUnions()
+	p.ReplaceEnums(enumRemap)
+	p.RmNilEnums()
+	// Use typedef names in declarations parsed later on.
+	p.typedefNames = typeRemap
+}
+
 // ### [ Helper functions ] ####################################################
 
 // Duplicate enum member format string.
@@ -249,6 +329,42 @@ func uniqueEnum(name string, members map[string]bool) string {
 	return newName
 }
 
+// pickAlias returns the typedef to use in place of the given type, or nil if
+// ambiguous. Typedefs named after the tag of the type are preferred.
+func pickAlias(t c.Type, defs []*c.VarDecl) *c.VarDecl {
+	if len(defs) == 1 {
+		return defs[0]
+	}
+	var tag string
+	switch t := t.(type) {
+	case *c.StructType:
+		tag = t.Tag
+	case *c.UnionType:
+		tag = t.Tag
+	case *c.EnumType:
+		tag = t.Tag
+	}
+	for _, def := range defs {
+		if def.Name == tag {
+			return def
+		}
+	}
+	return nil
+}
+
+// placeholderTypedef returns the typedef named by the tag of the given empty
+// type, or nil if not a placeholder for a typedef.
+func (p *Parser) placeholderTypedef(t c.Type, tag string, empty bool) *c.VarDecl {
+	if !empty {
+		return nil
+	}
+	def, ok := p.Types[tag].(*c.VarDecl)
+	if !ok || def.Type == t {
+		return nil
+	}
+	return def
+}
+
 // SliceIndex returns index within slece for which the func returns true
 func SliceIndex(limit int, predicate func(i int) bool) int {
 	for i := 0; i < limit; i++ {
@@ -565,6 +681,12 @@ func findEmptyEnum(p *Parser, tag string) *c.EnumType {
 // parseType parses the SYM type into the equivalent C type.
 func (p *Parser) parseType(t sym.Type, dims []uint32, tag string) c.Type {
 	u := p.parseBase(t.Base(), tag)
+	if def, ok := p.typedefNames[u]; ok {
+		u = def
+	} else if def, ok := p.Types[validName(tag)].(*c.VarDecl); ok && def.Type == u {
+		// Base type referred to by typedef name.
+		u = &c.TypedefType{Typedef: def}
+	}
 	return parseMods(u, t.Mods(), dims)
 }
 
@@ -573,6 +695,9 @@ func (p *Parser) parseBase(base sym.Base, tag string) c.Type {
 	tag = validName(tag)
 	switch base {
 	case sym.BaseNull:
+		if def, ok := p.Types["bool"].(*c.VarDecl); ok {
+			return &c.TypedefType{Typedef: def}
+		}
 		return p.Types["bool"]
 	case sym.BaseVoid:
 		return c.Void
@@ -584,6 +709,10 @@ func (p *Parser) parseBase(base sym.Base, tag string) c.Type {
 		return c.Int
 	case sym.BaseLong:
 		return c.Long
+	case sym.BaseFloat:
+		return c.Float
+	case sym.BaseDouble:
+		return c.Double
 	case sym.BaseStruct:
 		return p.findStruct(tag, 0, false)
 	case sym.BaseUnion:
@@ -600,7 +729,8 @@ func (p *Parser) parseBase(base sym.Base, tag string) c.Type {
 	case sym.BaseULong:
 		return c.ULong
 	default:
-		panic(fmt.Errorf("base type %q not yet supported", base))
+		p.fail("base type %q not yet supported", base)
+		return c.Int
 	}
 }
 
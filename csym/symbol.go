@@ -0,0 +1,208 @@
+package csym
+
+import (
+	"strings"
+
+	"github.com/mefistotelis/psx_mnd_sym/csym/c"
+)
+
+// A SymbolKind specifies what the value of a symbol refers to.
+type SymbolKind uint8
+
+// Symbol kinds; the zero value is used for symbols not classified.
+const (
+	// Address of data.
+	SymbolData SymbolKind = iota + 1
+	// Address of code.
+	SymbolCode
+	// Absolute value not referring to an address (e.g. linker constants such
+	// as _stacksize).
+	SymbolAbs
+)
+
+// String returns the string representation of the symbol kind.
+func (kind SymbolKind) String() string {
+	switch kind {
+	case SymbolData:
+		return "data"
+	case SymbolCode:
+		return "code"
+	case SymbolAbs:
+		return "abs"
+	}
+	return "unknown"
+}
+
+// MarshalText returns the textual representation of the symbol kind, as used
+// by the JSON output.
+func (kind SymbolKind) MarshalText() ([]byte, error) {
+	return []byte(kind.String()), nil
+}
+
+// A SymbolBinding specifies the visibility of a symbol.
+type SymbolBinding uint8
+
+// Symbol bindings; the zero value is used for symbols without binding
+// information.
+const (
+	// Global symbol (Name1 and Name2 symbols).
+	BindGlobal SymbolBinding = iota + 1
+	// Local symbol (Name5 and Name6 symbols).
+	BindLocal
+	// Absolute symbol, not referring to an address.
+	BindAbs
+)
+
+// String returns the string representation of the symbol binding.
+func (binding SymbolBinding) String() string {
+	switch binding {
+	case BindGlobal:
+		return "global"
+	case BindLocal:
+		return "local"
+	case BindAbs:
+		return "abs"
+	}
+	return "unknown"
+}
+
+// MarshalText returns the textual representation of the symbol binding, as
+// used by the JSON output.
+func (binding SymbolBinding) MarshalText() ([]byte, error) {
+	return []byte(binding.String()), nil
+}
+
+// Untyped returns the symbols of the overlay without a typed declaration (i.e.
+// a function, variable or label) of the same address or name, in order of
+// occurrence. Symbols repeated with the same name are only returned once.
+func (o *Overlay) Untyped() []*Symbol {
+	addrs := make(map[uint32]bool)
+	names := make(map[string]bool)
+	for _, f := range o.Funcs {
+		addrs[f.Addr] = true
+		names[f.Name] = true
+	}
+	for _, v := range o.Vars {
+		addrs[v.Addr] = true
+		names[v.Name] = true
+	}
+	for _, v := range o.Statics {
+		addrs[v.Addr] = true
+		names[v.Name] = true
+	}
+	for _, label := range o.Labels {
+		addrs[label.Addr] = true
+		names[label.Name] = true
+	}
+	var syms []*Symbol
+	for _, s := range o.Symbols {
+		if names[s.Name] || (s.Kind != SymbolAbs && addrs[s.Addr]) {
+			continue
+		}
+		names[s.Name] = true
+		syms = append(syms, s)
+	}
+	return syms
+}
+
+// UntypedAddrs returns the symbols of the overlay without a typed declaration
+// which refer to addresses, in order of occurrence; as Untyped, but without
+// absolute symbols. Absolute symbols hold values such as linker constants, so
+// they are not to be named, labeled or listed as addresses of code or data.
+func (o *Overlay) UntypedAddrs() []*Symbol {
+	var syms []*Symbol
+	for _, s := range o.Untyped() {
+		if s.Kind != SymbolAbs {
+			syms = append(syms, s)
+		}
+	}
+	return syms
+}
+
+// classifySymbols records the kind of each symbol. Symbols with values outside
+// of the address space of the PSX, or linker constants with values outside of
+// main RAM, are absolute and bound as such. Symbols within the code of the
+// overlay, which extends from its first function up to its first variable, are
+// code; other symbols are data.
+func (p *Parser) classifySymbols() {
+	overlays := append([]*Overlay{p.Overlay}, p.Overlays...)
+	for _, overlay := range overlays {
+		start, end := overlay.codeRange()
+		for _, s := range overlay.Symbols {
+			switch {
+			case !isAddr(s.Addr) || (isLinkerConst(s.Name) && !isRAMAddr(s.Addr)):
+				s.Kind = SymbolAbs
+				s.Binding = BindAbs
+			case start <= s.Addr && s.Addr < end:
+				s.Kind = SymbolCode
+			default:
+				s.Kind = SymbolData
+			}
+		}
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// codeRange returns the address range of the code of the overlay, extending
+// from the first function (or start of the overlay) up to the first variable
+// following the functions, or the end of the last function if there is none.
+func (o *Overlay) codeRange() (start, end uint32) {
+	if len(o.Funcs) == 0 {
+		return 0, 0
+	}
+	start = o.Funcs[0].Addr
+	for _, f := range o.Funcs {
+		if f.Addr < start {
+			start = f.Addr
+		}
+		fend := f.AddrEnd
+		if fend < f.Addr+f.Size {
+			fend = f.Addr + f.Size
+		}
+		if fend > end {
+			end = fend
+		}
+	}
+	if o.ID != 0 && o.Addr != 0 && o.Addr < start {
+		start = o.Addr
+	}
+	// Library code without debug information is commonly linked after the
+	// functions with debug information, and before the data.
+	dataStart := uint32(0)
+	vars := append(append([]*c.VarDecl(nil), o.Vars...), o.Statics...)
+	for _, v := range vars {
+		if v.Addr >= end && (dataStart == 0 || v.Addr < dataStart) {
+			dataStart = v.Addr
+		}
+	}
+	if dataStart != 0 {
+		end = dataStart
+	}
+	return start, end
+}
+
+// isAddr reports whether the given value is within the address space used by
+// PSX executables; i.e. the scratchpad, I/O ports, and the cached and uncached
+// mirrors of main RAM and the BIOS. Executables are never linked to the low
+// mirror of main RAM, so values below the scratchpad are assumed to be
+// absolute.
+func isAddr(v uint32) bool {
+	return v >= 0x1F000000
+}
+
+// isRAMAddr reports whether the given value is an address of main RAM, in any
+// of its mirrors.
+func isRAMAddr(v uint32) bool {
+	return v&0x1FFFFFFF < 0x00800000
+}
+
+// isLinkerConst reports whether the given symbol name is the name of a Psy-Q
+// linker constant (e.g. __SN_ENTRY_POINT or _stacksize).
+func isLinkerConst(name string) bool {
+	switch name {
+	case "_stacksize", "_ramsize":
+		return true
+	}
+	return strings.HasPrefix(name, "__SN_")
+}
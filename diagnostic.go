@@ -0,0 +1,22 @@
+package sym
+
+import (
+	"fmt"
+)
+
+// A Diagnostic describes a problem found while parsing a symbol file in
+// lenient mode.
+type Diagnostic struct {
+	// Offset of the symbol from the start of the file.
+	Offset int64
+	// Symbol kind.
+	Kind Kind
+	// Description of the problem.
+	Msg string
+}
+
+// String returns the string representation of the diagnostic.
+func (d *Diagnostic) String() string {
+	// 000056: 94 support for symbol class "Class(7)" not yet implemented
+	return fmt.Sprintf("%06x: %v %s", d.Offset, d.Kind, d.Msg)
+}
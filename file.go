@@ -22,33 +22,84 @@ type File struct {
 	Syms []*Symbol
 	// Parser options.
 	Opts *Options
+	// Problems found while parsing in lenient mode.
+	Diags []*Diagnostic
 }
 
-// String returns the string representation of the symbol file.
+// String returns the string representation of the symbol file. If the listing
+// cannot be completed, it ends with a line describing the error.
 func (f *File) String() string {
 	buf := &strings.Builder{}
+	if err := f.Dump(buf); err != nil {
+		fmt.Fprintf(buf, "error: %v\n", err)
+	}
+	return buf.String()
+}
+
+// A Filter selects the symbols included in a symbol file listing.
+type Filter struct {
+	// Symbol kinds to include; all kinds are included if empty.
+	Kinds map[Kind]bool
+	// Lowest symbol header value (usually an address) to include.
+	Start uint32
+	// Highest symbol header value (usually an address) to include; no upper
+	// bound if zero.
+	End uint32
+}
+
+// match reports whether the filter includes the given symbol.
+func (filter *Filter) match(sym *Symbol) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.Kinds) > 0 && !filter.Kinds[sym.Hdr.Kind] {
+		return false
+	}
+	if sym.Hdr.Value < filter.Start {
+		return false
+	}
+	if filter.End != 0 && sym.Hdr.Value > filter.End {
+		return false
+	}
+	return true
+}
+
+// Dump writes the listing of the symbol file to w, in the format of the
+// DUMPSYM.EXE tool of the Psy-Q SDK.
+func (f *File) Dump(w io.Writer) error {
+	return f.DumpFilter(w, nil)
+}
+
+// DumpFilter writes the listing of the symbols selected by the filter to w,
+// in the format of the DUMPSYM.EXE tool of the Psy-Q SDK. A nil filter
+// selects all symbols.
+func (f *File) DumpFilter(w io.Writer, filter *Filter) error {
+	bw := bufio.NewWriter(w)
 	offset := 0
-	fmt.Fprintln(buf, f.Hdr)
+	if _, err := fmt.Fprintln(bw, f.Hdr); err != nil {
+		return errors.WithStack(err)
+	}
 	offset += binary.Size(*f.Hdr)
 	var line int
 	for _, sym := range f.Syms {
 		bodyStr := sym.Body.String()
+		// Line numbers are tracked even for symbols not selected by the filter.
 		switch body := sym.Body.(type) {
 		case *IncSLD:
 			if line == 0 {
-				panic(fmt.Errorf("cannot use IncSLD symbol before associated SetSLD symbol"))
+				return errors.Errorf("%06x: cannot use IncSLD symbol before associated SetSLD symbol", offset)
 			}
 			line++
 			bodyStr = fmt.Sprintf("Inc SLD linenum (to %d)", line)
 		case *IncSLDByte:
 			if line == 0 {
-				panic(fmt.Errorf("cannot use IncSLDByte symbol before associated SetSLD symbol"))
+				return errors.Errorf("%06x: cannot use IncSLDByte symbol before associated SetSLD symbol", offset)
 			}
 			line += int(body.Inc)
 			bodyStr = fmt.Sprintf("Inc SLD linenum by byte %d (to %d)", body.Inc, line)
 		case *IncSLDWord:
 			if line == 0 {
-				panic(fmt.Errorf("cannot use IncSLDWord symbol before associated SetSLD symbol"))
+				return errors.Errorf("%06x: cannot use IncSLDWord symbol before associated SetSLD symbol", offset)
 			}
 			line += int(body.Inc)
 			bodyStr = fmt.Sprintf("Inc SLD linenum by word %d (to %d)", body.Inc, line)
@@ -57,16 +108,24 @@ func (f *File) String() string {
 		case *SetSLD2:
 			line = int(body.Line)
 		}
-		if len(bodyStr) == 0 {
-			// Symbol without body.
-			fmt.Fprintf(buf, "%06x: %s\n", offset, sym.Hdr)
-
-		} else {
-			fmt.Fprintf(buf, "%06x: %s %s\n", offset, sym.Hdr, bodyStr)
+		if filter.match(sym) {
+			var err error
+			if len(bodyStr) == 0 {
+				// Symbol without body.
+				_, err = fmt.Fprintf(bw, "%06x: %s\n", offset, sym.Hdr)
+			} else {
+				_, err = fmt.Fprintf(bw, "%06x: %s %s\n", offset, sym.Hdr, bodyStr)
+			}
+			if err != nil {
+				return errors.WithStack(err)
+			}
 		}
 		offset += sym.Size()
 	}
-	return buf.String()
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
 }
 
 // A FileHeader is a PS1 symbol file header.
@@ -106,21 +165,43 @@ func ParseBytes(b []byte, opts *Options) (*File, error) {
 // Parse parses the given PS1 symbol file, reading from r.
 func Parse(r io.Reader, opts *Options) (*File, error) {
 	// Parse file header.
-	f := &File{}
-	br := bufio.NewReader(r)
-	hdr, err := parseFileHeader(br)
+	d, err := NewDecoder(r, opts)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	f.Hdr = hdr
+	f := &File{}
+	f.Hdr = d.Hdr
 	f.Opts = opts
 
 	if f.Opts.Verbose { fmt.Printf("Parsing flattened tags...\n") }
 	// Parse symbols.
 	for {
-		sym, err := parseSymbol(br)
+		sym, offset, err := d.Next()
 		if err != nil {
-			if errors.Cause(err) == io.EOF {
+			if err == io.EOF {
+				break
+			}
+			if f.Opts.Lenient {
+				if _, ok := errors.Cause(err).(unknownKindError); ok {
+					more, rerr := f.keepRaw(d, sym, offset)
+					if rerr == nil {
+						if more {
+							continue
+						}
+						break
+					}
+					err = rerr
+				}
+				// The symbol is truncated by the end of the file; keep the
+				// symbols parsed so far.
+				diag := &Diagnostic{
+					Offset: offset,
+					Msg:    fmt.Sprintf("%v; remaining symbols skipped", errors.Cause(err)),
+				}
+				if sym != nil {
+					diag.Kind = sym.Hdr.Kind
+				}
+				f.Diags = append(f.Diags, diag)
 				break
 			}
 			return f, errors.WithStack(err)
@@ -131,6 +212,128 @@ func Parse(r io.Reader, opts *Options) (*File, error) {
 	return f, nil
 }
 
+// keepRaw keeps the symbol of unknown kind, the header of which has just been
+// read by d, with a raw body, and records a diagnostic. It reports whether
+// symbols may follow.
+func (f *File) keepRaw(d *Decoder, sym *Symbol, offset int64) (bool, error) {
+	body, more, err := d.rawBody(sym.Hdr.Kind)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	sym.Body = body
+	f.Syms = append(f.Syms, sym)
+	msg := "kept as raw body of name symbol"
+	if !more {
+		msg = fmt.Sprintf("symbol length unknown, remaining %d bytes kept as raw body", len(body.Data))
+	}
+	diag := &Diagnostic{
+		Offset: offset,
+		Kind:   sym.Hdr.Kind,
+		Msg:    fmt.Sprintf("support for symbol kind 0x%02X not yet implemented; %s", uint8(sym.Hdr.Kind), msg),
+	}
+	f.Diags = append(f.Diags, diag)
+	return more, nil
+}
+
+// A Decoder reads PS1 symbols one at a time from an input stream, without
+// keeping the symbols already read in memory.
+type Decoder struct {
+	// File header.
+	Hdr *FileHeader
+	// Parser options.
+	Opts *Options
+	// Input stream, tracking the number of bytes consumed.
+	r *countReader
+}
+
+// NewDecoder returns a new decoder reading from r. The file header is parsed
+// immediately and stored in the Hdr field of the decoder.
+func NewDecoder(r io.Reader, opts *Options) (*Decoder, error) {
+	cr := &countReader{r: bufio.NewReader(r)}
+	hdr, err := parseFileHeader(cr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	d := &Decoder{
+		Hdr:  hdr,
+		Opts: opts,
+		r:    cr,
+	}
+	return d, nil
+}
+
+// Next parses and returns the next symbol of the input stream, along with its
+// offset from the start of the file. Next returns io.EOF when there are no
+// more symbols to read.
+func (d *Decoder) Next() (*Symbol, int64, error) {
+	offset := d.r.n
+	sym, err := parseSymbol(d.r, d.Opts)
+	if err != nil {
+		if errors.Cause(err) == io.EOF {
+			if d.r.n == offset {
+				return nil, offset, io.EOF
+			}
+			// Symbol truncated by the end of the file.
+			err = io.ErrUnexpectedEOF
+		}
+		return sym, offset, errors.Wrapf(err, "unable to parse symbol at offset 0x%06x", offset)
+	}
+	return sym, offset, nil
+}
+
+// rawBody reads the body of a symbol of unknown kind, the header of which has
+// just been read. Kinds below 0x80 are assumed to have the body of all known
+// kinds in that range, a name prefixed by its length. For other kinds, there
+// is no way to find the start of the next symbol, so the remainder of the
+// input is kept as body. rawBody reports whether symbols may follow.
+func (d *Decoder) rawBody(kind Kind) (*RawBody, bool, error) {
+	if kind < KindIncSLD {
+		var n [1]byte
+		if _, err := io.ReadFull(d.r, n[:]); err != nil {
+			return nil, false, errors.WithStack(err)
+		}
+		body := &RawBody{
+			Data: make([]byte, 1+int(n[0])),
+		}
+		body.Data[0] = n[0]
+		if _, err := io.ReadFull(d.r, body.Data[1:]); err != nil {
+			return nil, false, errors.WithStack(err)
+		}
+		return body, true, nil
+	}
+	data, err := io.ReadAll(d.r)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	return &RawBody{Data: data}, false, nil
+}
+
+// Offset returns the offset from the start of the file of the next symbol to
+// be read.
+func (d *Decoder) Offset() int64 {
+	return d.r.n
+}
+
+// WriteTo writes the binary representation of the symbol file to w. It
+// implements the io.WriterTo interface.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	if err := writeFileHeader(bw, f.Hdr); err != nil {
+		return cw.n, errors.WithStack(err)
+	}
+	for _, sym := range f.Syms {
+		if err := writeSymbol(bw, sym); err != nil {
+			bw.Flush()
+			return cw.n, errors.WithStack(err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return cw.n, errors.WithStack(err)
+	}
+	return cw.n, nil
+}
+
 // parseFileHeader parses and returns a PS1 symbol file header.
 func parseFileHeader(r io.Reader) (*FileHeader, error) {
 	hdr := &FileHeader{}
@@ -146,3 +349,38 @@ func parseFileHeader(r io.Reader) (*FileHeader, error) {
 	}
 	return hdr, nil
 }
+
+// writeFileHeader writes the binary representation of a PS1 symbol file
+// header to w.
+func writeFileHeader(w io.Writer, hdr *FileHeader) error {
+	if err := struc.Pack(w, hdr); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// countReader counts the number of bytes read from the underlying reader.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read reads from the underlying reader into p.
+func (cr *countReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// countWriter counts the number of bytes written to the underlying writer.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p to the underlying writer.
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
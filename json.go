@@ -0,0 +1,74 @@
+package sym
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// jsonFile is the JSON representation of a symbol file.
+type jsonFile struct {
+	// File header.
+	Header jsonFileHeader
+	// Symbols.
+	Symbols []jsonSymbol
+}
+
+// jsonFileHeader is the JSON representation of a symbol file header.
+type jsonFileHeader struct {
+	// File signature; MND.
+	Signature string
+	// File format version.
+	Version uint8
+	// Target unit.
+	TargetUnit uint32
+}
+
+// jsonSymbol is the JSON representation of a symbol.
+type jsonSymbol struct {
+	// File offset of the symbol.
+	Offset int
+	// Address or value of symbol.
+	Value uint32
+	// Symbol kind.
+	Kind uint8
+	// Name of the symbol body type (e.g. FuncStart).
+	BodyType string
+	// Decoded symbol body.
+	Body SymbolBody
+}
+
+// WriteJSON writes the JSON representation of the symbol file to w, with the
+// file offset, header and decoded body fields of each symbol.
+func (f *File) WriteJSON(w io.Writer) error {
+	jf := jsonFile{
+		Header: jsonFileHeader{
+			Signature:  string(f.Hdr.Signature[:]),
+			Version:    f.Hdr.Version,
+			TargetUnit: f.Hdr.TargetUnit,
+		},
+		Symbols: make([]jsonSymbol, 0, len(f.Syms)),
+	}
+	offset := binary.Size(*f.Hdr)
+	for _, sym := range f.Syms {
+		js := jsonSymbol{
+			Offset:   offset,
+			Value:    sym.Hdr.Value,
+			Kind:     uint8(sym.Hdr.Kind),
+			BodyType: strings.TrimPrefix(fmt.Sprintf("%T", sym.Body), "*sym."),
+			Body:     sym.Body,
+		}
+		jf.Symbols = append(jf.Symbols, js)
+		offset += sym.Size()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(jf); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
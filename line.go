@@ -0,0 +1,246 @@
+package sym
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A LineEntry associates a line number in a source file with an address.
+type LineEntry struct {
+	// Overlay ID; zero for the default binary.
+	Overlay uint32
+	// Address.
+	Addr uint32
+	// Source file.
+	Path string
+	// Line number.
+	Line uint32
+	// End of a sequence of line numbers (e.g. EndSLD symbol); the address is
+	// not associated with any line.
+	End bool
+	// End address (exclusive) of the enclosing function, for line entries of
+	// function symbols; the entry does not cover addresses past the function.
+	// Zero for line entries of SLD symbols.
+	FuncEnd uint32
+}
+
+// covers reports whether the line entry covers the given address, which is
+// assumed not to precede the address of the entry.
+func (e *LineEntry) covers(addr uint32) bool {
+	return !e.End && (e.FuncEnd == 0 || addr < e.FuncEnd)
+}
+
+// An AddrRange is a range of addresses, from Start (inclusive) to End
+// (exclusive).
+type AddrRange struct {
+	// Overlay ID; zero for the default binary.
+	Overlay uint32
+	// Start address.
+	Start uint32
+	// End address; equal to Start if the extent of the range is unknown.
+	End uint32
+}
+
+// A LineTable maps between addresses and source lines, as specified by the
+// line number (SLD) and function symbols of a symbol file.
+type LineTable struct {
+	// Line entries, sorted by overlay and address; one per address.
+	Entries []*LineEntry
+}
+
+// NewLineTable returns the line table of the given symbol file. Line numbers
+// of SLD symbols and of function symbols are tracked separately, as functions
+// may be interleaved with SLD sequences of other source files. Where several
+// symbols specify the line of the same address, the last one is kept.
+func NewLineTable(f *File) (*LineTable, error) {
+	t := &LineTable{}
+	offset := binary.Size(*f.Hdr)
+	var (
+		// Current overlay.
+		overlay uint32
+		// Source file of the current SLD sequence.
+		sldPath string
+		// Current line number of the SLD sequence; zero if not yet set.
+		sldLine uint32
+		// Source file of the current function.
+		funcPath string
+		// Start line of the current function; zero if outside of function.
+		funcLine uint32
+		// Line entries of the current function.
+		funcEntries []*LineEntry
+	)
+	addSLD := func(addr uint32) {
+		entry := &LineEntry{
+			Overlay: overlay,
+			Addr:    addr,
+			Path:    sldPath,
+			Line:    sldLine,
+		}
+		t.Entries = append(t.Entries, entry)
+	}
+	addFunc := func(addr, line uint32) {
+		entry := &LineEntry{
+			Overlay: overlay,
+			Addr:    addr,
+			Path:    funcPath,
+			Line:    line,
+		}
+		t.Entries = append(t.Entries, entry)
+		funcEntries = append(funcEntries, entry)
+	}
+	for _, s := range f.Syms {
+		switch body := s.Body.(type) {
+		case *IncSLD:
+			if sldLine == 0 {
+				return nil, errors.Errorf("%06x: cannot use IncSLD symbol before associated SetSLD symbol", offset)
+			}
+			sldLine++
+			addSLD(s.Hdr.Value)
+		case *IncSLDByte:
+			if sldLine == 0 {
+				return nil, errors.Errorf("%06x: cannot use IncSLDByte symbol before associated SetSLD symbol", offset)
+			}
+			sldLine += uint32(body.Inc)
+			addSLD(s.Hdr.Value)
+		case *IncSLDWord:
+			if sldLine == 0 {
+				return nil, errors.Errorf("%06x: cannot use IncSLDWord symbol before associated SetSLD symbol", offset)
+			}
+			sldLine += uint32(body.Inc)
+			addSLD(s.Hdr.Value)
+		case *SetSLD:
+			sldLine = body.Line
+			addSLD(s.Hdr.Value)
+		case *SetSLD2:
+			sldPath = body.Path
+			sldLine = body.Line
+			addSLD(s.Hdr.Value)
+		case *EndSLD:
+			entry := &LineEntry{
+				Overlay: overlay,
+				Addr:    s.Hdr.Value,
+				End:     true,
+			}
+			t.Entries = append(t.Entries, entry)
+		case *FuncStart:
+			funcPath = body.Path
+			funcLine = body.Line
+			funcEntries = nil
+			addFunc(s.Hdr.Value, body.Line)
+		case *FuncEnd:
+			if funcLine == 0 {
+				break
+			}
+			addFunc(s.Hdr.Value, body.Line)
+			// The end address of the function symbol is the address of its
+			// last instruction.
+			for _, entry := range funcEntries {
+				entry.FuncEnd = s.Hdr.Value + 4
+			}
+			funcLine = 0
+			funcEntries = nil
+		case *BlockStart:
+			// Block line numbers are relative to the start of the function.
+			if funcLine != 0 {
+				addFunc(s.Hdr.Value, funcLine+body.Line-1)
+			}
+		case *BlockEnd:
+			if funcLine != 0 {
+				addFunc(s.Hdr.Value, funcLine+body.Line-1)
+			}
+		case *SetOverlay:
+			overlay = s.Hdr.Value
+		}
+		offset += s.Size()
+	}
+	less := func(i, j int) bool {
+		a, b := t.Entries[i], t.Entries[j]
+		if a.Overlay != b.Overlay {
+			return a.Overlay < b.Overlay
+		}
+		return a.Addr < b.Addr
+	}
+	sort.SliceStable(t.Entries, less)
+	// Collapse entries of the same address, keeping the last one.
+	entries := t.Entries[:0]
+	for i, e := range t.Entries {
+		if i+1 < len(t.Entries) {
+			next := t.Entries[i+1]
+			if next.Overlay == e.Overlay && next.Addr == e.Addr {
+				continue
+			}
+		}
+		entries = append(entries, e)
+	}
+	t.Entries = entries
+	return t, nil
+}
+
+// Lookup returns the line entry covering the given address of the overlay.
+// Addresses past the end of a function are not covered by its line entries.
+func (t *LineTable) Lookup(overlay, addr uint32) (*LineEntry, bool) {
+	// Index of the first entry past the address.
+	i := sort.Search(len(t.Entries), func(i int) bool {
+		e := t.Entries[i]
+		if e.Overlay != overlay {
+			return e.Overlay > overlay
+		}
+		return e.Addr > addr
+	})
+	if i == 0 {
+		return nil, false
+	}
+	e := t.Entries[i-1]
+	if e.Overlay != overlay || !e.covers(addr) {
+		return nil, false
+	}
+	return e, true
+}
+
+// Addrs returns the address ranges associated with the given line of a source
+// file. The path matches source files with the same path or file name,
+// ignoring case and the kind of path separators.
+func (t *LineTable) Addrs(path string, line uint32) []AddrRange {
+	var ranges []AddrRange
+	for i, e := range t.Entries {
+		if e.End || e.Line != line || !PathMatch(e.Path, path) {
+			continue
+		}
+		r := AddrRange{
+			Overlay: e.Overlay,
+			Start:   e.Addr,
+			End:     e.Addr,
+		}
+		if i+1 < len(t.Entries) && t.Entries[i+1].Overlay == e.Overlay {
+			r.End = t.Entries[i+1].Addr
+		}
+		if e.FuncEnd != 0 && (r.End == r.Start || r.End > e.FuncEnd) {
+			r.End = e.FuncEnd
+		}
+		// Merge with the previous range if adjacent or overlapping.
+		if n := len(ranges); n > 0 {
+			prev := &ranges[n-1]
+			if prev.Overlay == r.Overlay && r.Start <= prev.End {
+				if r.End > prev.End {
+					prev.End = r.End
+				}
+				continue
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// PathMatch reports whether the source file path matches the given path or
+// file name, ignoring case and the kind of path separators.
+func PathMatch(path, name string) bool {
+	clean := func(s string) string {
+		return strings.ToLower(strings.Replace(s, "/", `\`, -1))
+	}
+	path, name = clean(path), clean(name)
+	return path == name || strings.HasSuffix(path, `\`+name)
+}
@@ -3,5 +3,15 @@ package sym
 // Parsing options
 type Options struct {
     Verbose  bool
+    // Record problems as diagnostics instead of aborting the parsing. Symbols
+    // of unknown kinds not listed in RawKinds are kept as RawBody; below 0x80
+    // their body is taken to be a length-prefixed name, as for all known kinds
+    // in that range, and the parsing continues. Above, their length is unknown,
+    // so the remainder of the file is kept as their body. Truncated symbols at
+    // the end of the file are skipped.
+    Lenient  bool
+    // Body sizes in bytes of symbol kinds not known to the parser; such
+    // symbols are kept as RawBody instead of aborting the parsing.
+    RawKinds map[Kind]int
 }
 
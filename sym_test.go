@@ -1,9 +1,13 @@
 package sym_test
 
 import (
+	"bytes"
 	"crypto/sha1"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/mefistotelis/psx_mnd_sym"
@@ -33,7 +37,7 @@ func TestParseFile(t *testing.T) {
 			t.Skip()
 			continue
 		}
-		f, err := sym.ParseFile(g.path)
+		f, err := sym.ParseFile(g.path, &sym.Options{})
 		if err != nil {
 			t.Errorf("unable to parse %q; %v", g.path, err)
 			continue
@@ -45,6 +49,396 @@ func TestParseFile(t *testing.T) {
 	}
 }
 
+func TestWriteTo(t *testing.T) {
+	paths := []string{
+		"testdata/DIABPSX_SLPS-01416.sym",
+		"testdata/DIABPSX_easy_as_pie.sym",
+	}
+	for _, path := range paths {
+		if !exists(path) {
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("unable to read %q; %v", path, err)
+			continue
+		}
+		testRoundTrip(t, path, want, &sym.Options{})
+		testDecoder(t, path, want, &sym.Options{})
+	}
+	// Synthetic file covering every symbol kind.
+	f := &sym.File{
+		Hdr: &sym.FileHeader{Signature: [3]byte{'M', 'N', 'D'}, Version: 1},
+		Syms: []*sym.Symbol{
+			{Hdr: &sym.SymbolHeader{Value: 0x800b031c, Kind: sym.KindOverlay}, Body: &sym.Overlay{Length: 0x9e4, ID: 4}},
+			{Hdr: &sym.SymbolHeader{Value: 0, Kind: sym.KindName1}, Body: &sym.Name1{NameLen: 16, Name: "__RHS2_data_size"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010000, Kind: sym.KindName2}, Body: &sym.Name2{NameLen: 14, Name: "printattribute"}},
+			{Hdr: &sym.SymbolHeader{Value: 0, Kind: sym.KindName5}, Body: &sym.Name5{NameLen: 1, Name: "m"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x10604, Kind: sym.KindName6}, Body: &sym.Name6{NameLen: 7, Name: "DoTitle"}},
+			{Hdr: &sym.SymbolHeader{Value: 0, Kind: sym.KindDef}, Body: &sym.Def{Class: sym.ClassTPDEF, Type: 0xC, NameLen: 6, Name: "u_char"}},
+			{Hdr: &sym.SymbolHeader{Value: 0, Kind: sym.KindDef2}, Body: &sym.Def2{Class: sym.ClassMOS, Type: 0x34, Size: 4, DimsLen: 1, Dims: []uint32{1}, Name: "r", NameLen: 1}},
+			{Hdr: &sym.SymbolHeader{Value: 0x8001fefc, Kind: sym.KindFuncStart}, Body: &sym.FuncStart{FP: 29, FSize: 24, RetReg: 31, Mask: 0x80000000, MaskOffset: -8, Line: 88, PathLen: 8, Path: "TASKER.C", NameLen: 5, Name: "DoEpi"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x8001ff00, Kind: sym.KindBlockStart}, Body: &sym.BlockStart{Line: 1}},
+			{Hdr: &sym.SymbolHeader{Value: 0x8001ff08, Kind: sym.KindBlockEnd}, Body: &sym.BlockEnd{Line: 3}},
+			{Hdr: &sym.SymbolHeader{Value: 0x8001ff4c, Kind: sym.KindFuncEnd}, Body: &sym.FuncEnd{Line: 91}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010000, Kind: sym.KindSetSLD2}, Body: &sym.SetSLD2{Line: 115, PathLen: 12, Path: "NULLFUNC.ASM"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010004, Kind: sym.KindIncSLD}, Body: &sym.IncSLD{}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010008, Kind: sym.KindIncSLDByte}, Body: &sym.IncSLDByte{Inc: 2}},
+			{Hdr: &sym.SymbolHeader{Value: 0x8001000c, Kind: sym.KindIncSLDWord}, Body: &sym.IncSLDWord{Inc: 276}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010010, Kind: sym.KindSetSLD}, Body: &sym.SetSLD{Line: 88}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010014, Kind: sym.KindEndSLD}, Body: &sym.EndSLD{}},
+			{Hdr: &sym.SymbolHeader{Value: 4, Kind: sym.KindSetOverlay}, Body: &sym.SetOverlay{}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010018, Kind: 0x9C}, Body: &sym.RawBody{Data: []byte{1, 2, 3, 4}}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if _, err := f.WriteTo(buf); err != nil {
+		t.Fatalf("unable to write synthetic symbol file; %v", err)
+	}
+	opts := &sym.Options{
+		RawKinds: map[sym.Kind]int{0x9C: 4},
+	}
+	testRoundTrip(t, "synthetic", buf.Bytes(), opts)
+	testDecoder(t, "synthetic", buf.Bytes(), opts)
+}
+
+func TestWriteRenamed(t *testing.T) {
+	f := &sym.File{
+		Hdr: &sym.FileHeader{Signature: [3]byte{'M', 'N', 'D'}, Version: 1},
+		Syms: []*sym.Symbol{
+			{Hdr: &sym.SymbolHeader{Value: 0x80010000, Kind: sym.KindName2}, Body: &sym.Name2{NameLen: 4, Name: "main"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010000, Kind: sym.KindSetSLD2}, Body: &sym.SetSLD2{Line: 1, PathLen: 5, Path: "A.ASM"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x8001fefc, Kind: sym.KindFuncStart}, Body: &sym.FuncStart{Line: 88, PathLen: 8, Path: "TASKER.C", NameLen: 5, Name: "DoEpi"}},
+			{Hdr: &sym.SymbolHeader{Value: 0, Kind: sym.KindDef2}, Body: &sym.Def2{Class: sym.ClassMOS, Type: 0x34, Size: 4, DimsLen: 1, Dims: []uint32{1}, TagLen: 1, Tag: "T", NameLen: 1, Name: "r"}},
+		},
+	}
+	// Rename symbols without updating their length fields.
+	f.Syms[0].Body.(*sym.Name2).Name = "start"
+	f.Syms[1].Body.(*sym.SetSLD2).Path = "BOOT.ASM"
+	f.Syms[2].Body.(*sym.FuncStart).Name = "DoEpilogue"
+	def := f.Syms[3].Body.(*sym.Def2)
+	def.Tag = "Tag"
+	def.Dims = []uint32{2, 3}
+	want := 8 // size of file header
+	for _, s := range f.Syms {
+		want += s.Size()
+	}
+	buf := &bytes.Buffer{}
+	if _, err := f.WriteTo(buf); err != nil {
+		t.Fatalf("unable to write renamed symbols; %v", err)
+	}
+	if buf.Len() != want {
+		t.Errorf("size mismatch; expected %d bytes, got %d bytes", want, buf.Len())
+	}
+	// The symbols written are left unchanged.
+	if got := f.Syms[2].Body.(*sym.FuncStart).NameLen; got != 5 {
+		t.Errorf("name length of written symbol changed; expected 5, got %d", got)
+	}
+	if def.TagLen != 1 || def.DimsLen != 1 {
+		t.Errorf("lengths of written symbol changed; got %v", def)
+	}
+	g, err := sym.ParseBytes(buf.Bytes(), &sym.Options{})
+	if err != nil {
+		t.Fatalf("unable to parse renamed symbols; %v", err)
+	}
+	if got := g.Syms[2].Body.(*sym.FuncStart).Name; got != "DoEpilogue" {
+		t.Errorf("function name mismatch; expected %q, got %q", "DoEpilogue", got)
+	}
+	if got := g.Syms[3].Body.(*sym.Def2); got.Tag != "Tag" || len(got.Dims) != 2 {
+		t.Errorf("definition mismatch; got %v", got)
+	}
+	testDecoder(t, "renamed", buf.Bytes(), &sym.Options{})
+}
+
+func TestParseLenient(t *testing.T) {
+	// Symbol file with a symbol of unknown kind 0x9C, followed by a known one.
+	b := []byte{
+		'M', 'N', 'D', 1, 0, 0, 0, 0, // file header
+		0x00, 0x00, 0x01, 0x80, 0x02, 4, 'm', 'a', 'i', 'n', // Name2
+		0x18, 0x00, 0x01, 0x80, 0x9C, 1, 2, 3, 4, // unknown kind
+		0x04, 0x00, 0x01, 0x80, 0x80, // IncSLD
+	}
+	if _, err := sym.ParseBytes(b, &sym.Options{}); err == nil {
+		t.Errorf("expected error for unknown symbol kind in strict mode")
+	}
+	// Without the size of the unknown kind, the remainder of the file is kept
+	// as its body.
+	f, err := sym.ParseBytes(b, &sym.Options{Lenient: true})
+	if err != nil {
+		t.Fatalf("unable to parse in lenient mode; %v", err)
+	}
+	if len(f.Syms) != 2 {
+		t.Fatalf("symbol count mismatch; expected 2, got %d", len(f.Syms))
+	}
+	if body, ok := f.Syms[1].Body.(*sym.RawBody); !ok || len(body.Data) != 9 {
+		t.Errorf("expected RawBody of 9 bytes for unknown symbol kind, got %v", f.Syms[1].Body)
+	}
+	if len(f.Diags) != 1 {
+		t.Fatalf("diagnostic count mismatch; expected 1, got %d", len(f.Diags))
+	}
+	if d := f.Diags[0]; d.Offset != 0x12 || d.Kind != 0x9C {
+		t.Errorf("diagnostic mismatch; got %v", d)
+	}
+	testRoundTrip(t, "lenient", b, &sym.Options{Lenient: true})
+	// Unknown kinds below 0x80 are kept as names and the parsing continues.
+	c := []byte{
+		'M', 'N', 'D', 1, 0, 0, 0, 0, // file header
+		0x00, 0x00, 0x01, 0x80, 0x03, 4, 'm', 'a', 'i', 'n', // unknown name kind
+		0x04, 0x00, 0x01, 0x80, 0x80, // IncSLD
+	}
+	f, err = sym.ParseBytes(c, &sym.Options{Lenient: true})
+	if err != nil {
+		t.Fatalf("unable to parse in lenient mode; %v", err)
+	}
+	if len(f.Syms) != 2 || len(f.Diags) != 1 {
+		t.Errorf("expected 2 symbols and 1 diagnostic, got %d symbols and %d diagnostics", len(f.Syms), len(f.Diags))
+	}
+	testRoundTrip(t, "lenient name", c, &sym.Options{Lenient: true})
+	// Symbols truncated by the end of the file are skipped.
+	f, err = sym.ParseBytes(b[:len(b)-3], &sym.Options{Lenient: true, RawKinds: map[sym.Kind]int{0x9C: 4}})
+	if err != nil {
+		t.Fatalf("unable to parse in lenient mode; %v", err)
+	}
+	if len(f.Syms) != 2 || len(f.Diags) != 1 {
+		t.Errorf("expected 2 symbols and 1 diagnostic, got %d symbols and %d diagnostics", len(f.Syms), len(f.Diags))
+	}
+	// With the size of the unknown kind, it is kept and the parsing continues.
+	f, err = sym.ParseBytes(b, &sym.Options{Lenient: true, RawKinds: map[sym.Kind]int{0x9C: 4}})
+	if err != nil {
+		t.Fatalf("unable to parse in lenient mode; %v", err)
+	}
+	if len(f.Syms) != 3 || len(f.Diags) != 0 {
+		t.Errorf("expected 3 symbols and no diagnostics, got %d symbols and %d diagnostics", len(f.Syms), len(f.Diags))
+	}
+	if _, ok := f.Syms[1].Body.(*sym.RawBody); !ok {
+		t.Errorf("expected RawBody for unknown symbol kind, got %T", f.Syms[1].Body)
+	}
+}
+
+// testDecoder decodes the given symbol file contents one symbol at a time and
+// verifies the reported offsets.
+func testDecoder(t *testing.T, name string, b []byte, opts *sym.Options) {
+	d, err := sym.NewDecoder(bytes.NewReader(b), opts)
+	if err != nil {
+		t.Errorf("unable to create decoder for %q; %v", name, err)
+		return
+	}
+	want := int64(8) // size of file header
+	for {
+		s, offset, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Errorf("%q: unable to decode symbol at offset 0x%06x; %v", name, offset, err)
+			return
+		}
+		if offset != want {
+			t.Errorf("%q: offset mismatch; expected 0x%06x, got 0x%06x", name, want, offset)
+		}
+		want += int64(s.Size())
+	}
+	if want != int64(len(b)) {
+		t.Errorf("%q: decoded %d bytes, expected %d", name, want, len(b))
+	}
+}
+
+func TestDumpInvalidSLD(t *testing.T) {
+	f := &sym.File{
+		Hdr: &sym.FileHeader{Signature: [3]byte{'M', 'N', 'D'}, Version: 1},
+		Syms: []*sym.Symbol{
+			{Hdr: &sym.SymbolHeader{Value: 0x80010004, Kind: sym.KindIncSLD}, Body: &sym.IncSLD{}},
+		},
+	}
+	err := f.Dump(io.Discard)
+	if err == nil {
+		t.Fatalf("expected error for IncSLD symbol before SetSLD symbol")
+	}
+	if want := "000008: cannot use IncSLD symbol before associated SetSLD symbol"; err.Error() != want {
+		t.Errorf("error mismatch; expected %q, got %q", want, err.Error())
+	}
+	if want := "error: 000008: cannot use IncSLD symbol before associated SetSLD symbol\n"; !strings.HasSuffix(f.String(), want) {
+		t.Errorf("listing mismatch; expected suffix %q, got %q", want, f.String())
+	}
+}
+
+func TestLineTable(t *testing.T) {
+	f := &sym.File{
+		Hdr: &sym.FileHeader{Signature: [3]byte{'M', 'N', 'D'}, Version: 1},
+		Syms: []*sym.Symbol{
+			{Hdr: &sym.SymbolHeader{Value: 0x80010000, Kind: sym.KindSetSLD2}, Body: &sym.SetSLD2{Line: 10, PathLen: 8, Path: `C:\A\B.C`}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010004, Kind: sym.KindIncSLD}, Body: &sym.IncSLD{}},
+			{Hdr: &sym.SymbolHeader{Value: 0x8001000c, Kind: sym.KindIncSLDByte}, Body: &sym.IncSLDByte{Inc: 2}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010010, Kind: sym.KindEndSLD}, Body: &sym.EndSLD{}},
+			{Hdr: &sym.SymbolHeader{Value: 4, Kind: sym.KindSetOverlay}, Body: &sym.SetOverlay{}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010000, Kind: sym.KindFuncStart}, Body: &sym.FuncStart{Line: 5, PathLen: 5, Path: "OVL.C", NameLen: 1, Name: "f"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010008, Kind: sym.KindBlockStart}, Body: &sym.BlockStart{Line: 2}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80010010, Kind: sym.KindFuncEnd}, Body: &sym.FuncEnd{Line: 8}},
+		},
+	}
+	table, err := sym.NewLineTable(f)
+	if err != nil {
+		t.Fatalf("unable to create line table; %v", err)
+	}
+	lookups := []struct {
+		overlay, addr uint32
+		path          string
+		line          uint32
+		ok            bool
+	}{
+		{overlay: 0, addr: 0x80010000, path: `C:\A\B.C`, line: 10, ok: true},
+		{overlay: 0, addr: 0x80010008, path: `C:\A\B.C`, line: 11, ok: true},
+		{overlay: 0, addr: 0x8001000c, path: `C:\A\B.C`, line: 13, ok: true},
+		{overlay: 0, addr: 0x80010010, ok: false},
+		{overlay: 0, addr: 0x8000fffc, ok: false},
+		{overlay: 4, addr: 0x8001000c, path: "OVL.C", line: 6, ok: true},
+		{overlay: 4, addr: 0x80010010, path: "OVL.C", line: 8, ok: true},
+		{overlay: 4, addr: 0x80010014, ok: false},
+	}
+	for _, l := range lookups {
+		e, ok := table.Lookup(l.overlay, l.addr)
+		if ok != l.ok {
+			t.Errorf("overlay %x address 0x%08X: expected found %v, got %v", l.overlay, l.addr, l.ok, ok)
+			continue
+		}
+		if ok && (e.Path != l.path || e.Line != l.line) {
+			t.Errorf("overlay %x address 0x%08X: expected %s:%d, got %s:%d", l.overlay, l.addr, l.path, l.line, e.Path, e.Line)
+		}
+	}
+	got := table.Addrs("b.c", 11)
+	want := []sym.AddrRange{{Overlay: 0, Start: 0x80010004, End: 0x8001000c}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("address ranges of b.c:11 mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestLineTableFuncs(t *testing.T) {
+	f := &sym.File{
+		Hdr: &sym.FileHeader{Signature: [3]byte{'M', 'N', 'D'}, Version: 1},
+		Syms: []*sym.Symbol{
+			{Hdr: &sym.SymbolHeader{Value: 0x80020000, Kind: sym.KindSetSLD2}, Body: &sym.SetSLD2{Line: 10, PathLen: 5, Path: "A.ASM"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80020010, Kind: sym.KindFuncStart}, Body: &sym.FuncStart{Line: 5, PathLen: 3, Path: "B.C", NameLen: 1, Name: "g"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80020010, Kind: sym.KindSetSLD}, Body: &sym.SetSLD{Line: 5}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80020018, Kind: sym.KindFuncEnd}, Body: &sym.FuncEnd{Line: 7}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80020020, Kind: sym.KindIncSLD}, Body: &sym.IncSLD{}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80020024, Kind: sym.KindEndSLD}, Body: &sym.EndSLD{}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80020030, Kind: sym.KindFuncStart}, Body: &sym.FuncStart{Line: 20, PathLen: 3, Path: "B.C", NameLen: 1, Name: "h"}},
+			{Hdr: &sym.SymbolHeader{Value: 0x80020038, Kind: sym.KindFuncEnd}, Body: &sym.FuncEnd{Line: 22}},
+		},
+	}
+	table, err := sym.NewLineTable(f)
+	if err != nil {
+		t.Fatalf("unable to create line table; %v", err)
+	}
+	lookups := []struct {
+		addr uint32
+		path string
+		line uint32
+		ok   bool
+	}{
+		{addr: 0x80020000, path: "A.ASM", line: 10, ok: true},
+		// Duplicate address of FuncStart and SetSLD; the last one is kept.
+		{addr: 0x80020010, path: "A.ASM", line: 5, ok: true},
+		{addr: 0x80020018, path: "B.C", line: 7, ok: true},
+		// Gap between the end of g and the next SLD entry.
+		{addr: 0x8002001c, ok: false},
+		// The SLD path and line are not affected by the function g.
+		{addr: 0x80020020, path: "A.ASM", line: 6, ok: true},
+		// Gap between the end of the SLD sequence and h.
+		{addr: 0x80020028, ok: false},
+		{addr: 0x80020030, path: "B.C", line: 20, ok: true},
+		{addr: 0x80020038, path: "B.C", line: 22, ok: true},
+		// Past the end of h.
+		{addr: 0x8002003c, ok: false},
+		{addr: 0x80021000, ok: false},
+	}
+	for _, l := range lookups {
+		e, ok := table.Lookup(0, l.addr)
+		if ok != l.ok {
+			t.Errorf("address 0x%08X: expected found %v, got %v", l.addr, l.ok, ok)
+			continue
+		}
+		if ok && (e.Path != l.path || e.Line != l.line) {
+			t.Errorf("address 0x%08X: expected %s:%d, got %s:%d", l.addr, l.path, l.line, e.Path, e.Line)
+		}
+	}
+	for i := 1; i < len(table.Entries); i++ {
+		if table.Entries[i-1].Addr == table.Entries[i].Addr {
+			t.Errorf("duplicate line entries of address 0x%08X", table.Entries[i].Addr)
+		}
+	}
+	got := table.Addrs("b.c", 22)
+	want := []sym.AddrRange{{Overlay: 0, Start: 0x80020038, End: 0x8002003c}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("address ranges of b.c:22 mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	f := &sym.File{
+		Hdr: &sym.FileHeader{Signature: [3]byte{'M', 'N', 'D'}, Version: 1},
+		Syms: []*sym.Symbol{
+			{Hdr: &sym.SymbolHeader{Value: 0x800b031c, Kind: sym.KindOverlay}, Body: &sym.Overlay{Length: 0x9e4, ID: 4}},
+			{Hdr: &sym.SymbolHeader{Value: 0x8001fefc, Kind: sym.KindFuncStart}, Body: &sym.FuncStart{FP: 29, FSize: 24, RetReg: 31, Line: 88, PathLen: 8, Path: "TASKER.C", NameLen: 5, Name: "DoEpi"}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := f.WriteJSON(buf); err != nil {
+		t.Fatalf("unable to write JSON; %v", err)
+	}
+	var got struct {
+		Header struct {
+			Signature string
+			Version   int
+		}
+		Symbols []struct {
+			Offset   int
+			Value    uint32
+			Kind     int
+			BodyType string
+			Body     map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unable to decode JSON; %v", err)
+	}
+	if got.Header.Signature != "MND" || got.Header.Version != 1 {
+		t.Errorf("header mismatch; got %+v", got.Header)
+	}
+	if len(got.Symbols) != 2 {
+		t.Fatalf("symbol count mismatch; expected 2, got %d", len(got.Symbols))
+	}
+	fn := got.Symbols[1]
+	if fn.Offset != 8+5+8 || fn.Value != 0x8001fefc || fn.Kind != 0x8C || fn.BodyType != "FuncStart" {
+		t.Errorf("function start symbol mismatch; got %+v", fn)
+	}
+	if fn.Body["Name"] != "DoEpi" || fn.Body["FSize"] != float64(24) {
+		t.Errorf("function start body mismatch; got %v", fn.Body)
+	}
+}
+
+// testRoundTrip parses the given symbol file contents, writes them back and
+// verifies that the output is identical to the input.
+func testRoundTrip(t *testing.T, name string, want []byte, opts *sym.Options) {
+	f, err := sym.ParseBytes(want, opts)
+	if err != nil {
+		t.Errorf("unable to parse %q; %v", name, err)
+		return
+	}
+	buf := &bytes.Buffer{}
+	n, err := f.WriteTo(buf)
+	if err != nil {
+		t.Errorf("unable to write %q; %v", name, err)
+		return
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("%q: byte count mismatch; expected %d, got %d", name, buf.Len(), n)
+	}
+	if !bytes.Equal(want, buf.Bytes()) {
+		t.Errorf("%q: round-trip mismatch; expected %d bytes, got %d bytes", name, len(want), buf.Len())
+	}
+}
+
 // exists reports whether the given file or directory exists.
 func exists(path string) bool {
 	_, err := os.Stat(path)
@@ -51,7 +51,7 @@ type SymbolBody interface {
 }
 
 // parseSymbol parses and returns a PS1 symbol.
-func parseSymbol(r io.Reader) (*Symbol, error) {
+func parseSymbol(r io.Reader, opts *Options) (*Symbol, error) {
 	// Parse symbol header.
 	sym := &Symbol{}
 	hdr, err := parseSymbolHeader(r)
@@ -61,7 +61,7 @@ func parseSymbol(r io.Reader) (*Symbol, error) {
 	sym.Hdr = hdr
 
 	// Parse symbol body.
-	body, err := parseSymbolBody(r, hdr.Kind)
+	body, err := parseSymbolBody(r, hdr.Kind, opts)
 	if err != nil {
 		return sym, errors.WithStack(err)
 	}
@@ -79,7 +79,7 @@ func parseSymbolHeader(r io.Reader) (*SymbolHeader, error) {
 }
 
 // parseSymbolBody parses and returns a PS1 symbol body.
-func parseSymbolBody(r io.Reader, kind Kind) (SymbolBody, error) {
+func parseSymbolBody(r io.Reader, kind Kind, opts *Options) (SymbolBody, error) {
 	parse := func(body SymbolBody) (SymbolBody, error) {
 		if err := struc.Unpack(r, body); err != nil {
 			return nil, errors.WithStack(err)
@@ -92,9 +92,9 @@ func parseSymbolBody(r io.Reader, kind Kind) (SymbolBody, error) {
 	case KindName2:
 		return parse(&Name2{})
 	case KindName5:
-		return parse(&Name2{})
+		return parse(&Name5{})
 	case KindName6:
-		return parse(&Name2{})
+		return parse(&Name6{})
 	case KindIncSLD:
 		// empty body.
 		return &IncSLD{}, nil
@@ -127,13 +127,155 @@ func parseSymbolBody(r io.Reader, kind Kind) (SymbolBody, error) {
 		// empty body.
 		return &SetOverlay{}, nil
 	default:
-		return nil, errors.Errorf("support for symbol kind 0x%02X not yet implemented", uint8(kind))
+		// Keep the body of unknown symbol kinds with a known length.
+		if opts != nil {
+			if size, ok := opts.RawKinds[kind]; ok {
+				body := &RawBody{
+					Data: make([]byte, size),
+				}
+				if _, err := io.ReadFull(r, body.Data); err != nil {
+					return nil, errors.WithStack(err)
+				}
+				return body, nil
+			}
+		}
+		return nil, errors.WithStack(unknownKindError(kind))
+	}
+}
+
+// An unknownKindError reports a symbol of unknown kind, the body size of which
+// is not known.
+type unknownKindError Kind
+
+// Error returns the error message of the unknown kind error.
+func (kind unknownKindError) Error() string {
+	return fmt.Sprintf("support for symbol kind 0x%02X not yet implemented (body size may be given in Options.RawKinds)", uint8(kind))
+}
+
+// writeSymbol writes the binary representation of a PS1 symbol to w.
+func writeSymbol(w io.Writer, sym *Symbol) error {
+	if err := writeSymbolHeader(w, sym.Hdr); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := writeSymbolBody(w, sym.Hdr.Kind, sym.Body); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// writeSymbolHeader writes the binary representation of a PS1 symbol header to
+// w.
+func writeSymbolHeader(w io.Writer, hdr *SymbolHeader) error {
+	if err := struc.Pack(w, hdr); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// writeSymbolBody writes the binary representation of a PS1 symbol body to w.
+func writeSymbolBody(w io.Writer, kind Kind, body SymbolBody) error {
+	write := func(body SymbolBody) error {
+		if err := struc.Pack(w, body); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	// Length fields are computed from the strings and slices they prefix, which
+	// may have been modified since parsing, so verify that they still fit. The
+	// lengths are set on a copy of the body, leaving the symbol unchanged.
+	checkLen := func(field, s string) error {
+		if len(s) > 0xFF {
+			return errors.Errorf("%s of symbol kind 0x%02X too long; expected <= 255 bytes, got %d", field, uint8(kind), len(s))
+		}
+		return nil
+	}
+	switch body := body.(type) {
+	case *Name1:
+		if err := checkLen("name", body.Name); err != nil {
+			return err
+		}
+		b := *body
+		b.NameLen = uint8(len(b.Name))
+		return write(&b)
+	case *Name2:
+		if err := checkLen("name", body.Name); err != nil {
+			return err
+		}
+		b := *body
+		b.NameLen = uint8(len(b.Name))
+		return write(&b)
+	case *Name5:
+		if err := checkLen("name", body.Name); err != nil {
+			return err
+		}
+		b := *body
+		b.NameLen = uint8(len(b.Name))
+		return write(&b)
+	case *Name6:
+		if err := checkLen("name", body.Name); err != nil {
+			return err
+		}
+		b := *body
+		b.NameLen = uint8(len(b.Name))
+		return write(&b)
+	case *IncSLD, *EndSLD, *SetOverlay:
+		// empty body.
+		return nil
+	case *IncSLDByte, *IncSLDWord, *SetSLD, *FuncEnd, *BlockStart, *BlockEnd, *Overlay:
+		return write(body)
+	case *SetSLD2:
+		if err := checkLen("path", body.Path); err != nil {
+			return err
+		}
+		b := *body
+		b.PathLen = uint8(len(b.Path))
+		return write(&b)
+	case *FuncStart:
+		if err := checkLen("path", body.Path); err != nil {
+			return err
+		}
+		if err := checkLen("name", body.Name); err != nil {
+			return err
+		}
+		b := *body
+		b.PathLen = uint8(len(b.Path))
+		b.NameLen = uint8(len(b.Name))
+		return write(&b)
+	case *Def:
+		if err := checkLen("name", body.Name); err != nil {
+			return err
+		}
+		b := *body
+		b.NameLen = uint8(len(b.Name))
+		return write(&b)
+	case *RawBody:
+		if _, err := w.Write(body.Data); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	case *Def2:
+		if len(body.Dims) > 0xFFFF {
+			return errors.Errorf("dimensions of symbol kind 0x%02X too long; expected <= 65535 entries, got %d", uint8(kind), len(body.Dims))
+		}
+		if err := checkLen("tag", body.Tag); err != nil {
+			return err
+		}
+		if err := checkLen("name", body.Name); err != nil {
+			return err
+		}
+		b := *body
+		b.DimsLen = uint16(len(b.Dims))
+		b.TagLen = uint8(len(b.Tag))
+		b.NameLen = uint8(len(b.Name))
+		return write(&b)
+	default:
+		return errors.Errorf("support for writing symbol body %T not yet implemented", body)
 	}
 }
 
 // --- [ 0x01 ] ----------------------------------------------------------------
 
-// A Name1 symbol specifies the name of a symbol.
+// A Name1 symbol specifies the name of a global symbol.
 //
 // Value of the symbol header specifies the associated address.
 type Name1 struct {
@@ -151,12 +293,12 @@ func (body *Name1) String() string {
 
 // BodySize returns the size of the symbol body in bytes.
 func (body *Name1) BodySize() int {
-	return 1 + int(body.NameLen)
+	return 1 + len(body.Name)
 }
 
 // --- [ 0x02 ] ----------------------------------------------------------------
 
-// A Name2 symbol specifies the name of a symbol.
+// A Name2 symbol specifies the name of a global symbol.
 //
 // Value of the symbol header specifies the associated address.
 type Name2 struct {
@@ -174,12 +316,16 @@ func (body *Name2) String() string {
 
 // BodySize returns the size of the symbol body in bytes.
 func (body *Name2) BodySize() int {
-	return 1 + int(body.NameLen)
+	return 1 + len(body.Name)
 }
 
 // --- [ 0x05 ] ----------------------------------------------------------------
 
-// A Name5 symbol specifies the name of a symbol.
+// A Name5 symbol specifies the name of a symbol local to its module, not
+// exported to other modules.
+//
+// Name5 and Name6 symbols share the same layout, and what distinguishes the
+// two kinds is not known; both are local.
 //
 // Value of the symbol header specifies the associated address.
 type Name5 struct {
@@ -197,12 +343,13 @@ func (body *Name5) String() string {
 
 // BodySize returns the size of the symbol body in bytes.
 func (body *Name5) BodySize() int {
-	return 1 + int(body.NameLen)
+	return 1 + len(body.Name)
 }
 
 // --- [ 0x06 ] ----------------------------------------------------------------
 
-// A Name6 symbol specifies the name of a symbol.
+// A Name6 symbol specifies the name of a symbol local to its module, as a
+// Name5 symbol does (e.g. the static function DoTitle).
 //
 // Value of the symbol header specifies the associated address.
 type Name6 struct {
@@ -220,7 +367,7 @@ func (body *Name6) String() string {
 
 // BodySize returns the size of the symbol body in bytes.
 func (body *Name6) BodySize() int {
-	return 1 + int(body.NameLen)
+	return 1 + len(body.Name)
 }
 
 // --- [ 0x80 ] ----------------------------------------------------------------
@@ -325,7 +472,7 @@ func (body *SetSLD2) String() string {
 
 // BodySize returns the size of the symbol body in bytes.
 func (body *SetSLD2) BodySize() int {
-	return 4 + 1 + int(body.PathLen)
+	return 4 + 1 + len(body.Path)
 }
 
 // --- [ 0x8A ] ----------------------------------------------------------------
@@ -400,7 +547,7 @@ func (body *FuncStart) String() string {
 
 // BodySize returns the size of the symbol body in bytes.
 func (body *FuncStart) BodySize() int {
-	return 2 + 4 + 2 + 4 + 4 + 4 + 1 + int(body.PathLen) + 1 + int(body.NameLen)
+	return 2 + 4 + 2 + 4 + 4 + 4 + 1 + len(body.Path) + 1 + len(body.Name)
 }
 
 // --- [ 0x8E ] ----------------------------------------------------------------
@@ -492,7 +639,7 @@ func (body *Def) String() string {
 
 // BodySize returns the size of the symbol body in bytes.
 func (body *Def) BodySize() int {
-	return 2 + 2 + 4 + 1 + int(body.NameLen)
+	return 2 + 2 + 4 + 1 + len(body.Name)
 }
 
 // --- [ 0x96 ] ----------------------------------------------------------------
@@ -530,7 +677,7 @@ func (body *Def2) String() string {
 		dd[i] = strconv.Itoa(int(dim))
 	}
 	dims := fmt.Sprintf("%d %s", body.DimsLen, strings.Join(dd, " "))
-	if body.DimsLen == 0 {
+	if len(body.Dims) == 0 {
 		dims = "0"
 	}
 	return fmt.Sprintf("Def2 class %v type %v size %v dims %s tag %v name %v", body.Class, body.Type, body.Size, dims, body.Tag, body.Name)
@@ -538,7 +685,7 @@ func (body *Def2) String() string {
 
 // BodySize returns the size of the symbol body in bytes.
 func (body *Def2) BodySize() int {
-	return 2 + 2 + 4 + 2 + int(4*body.DimsLen) + 1 + int(body.TagLen) + 1 + int(body.NameLen)
+	return 2 + 2 + 4 + 2 + 4*len(body.Dims) + 1 + len(body.Tag) + 1 + len(body.Name)
 }
 
 // --- [ 0x98 ] ----------------------------------------------------------------
@@ -584,3 +731,33 @@ func (body *SetOverlay) String() string {
 func (body *SetOverlay) BodySize() int {
 	return 0
 }
+
+// --- [ Unknown ] -------------------------------------------------------------
+
+// A RawBody holds the undecoded contents of a symbol body of unknown kind. The
+// length of such bodies is specified by the RawKinds parser option, or guessed
+// in lenient mode.
+//
+// Value of the symbol header is not interpreted.
+type RawBody struct {
+	// Body contents.
+	Data []byte
+}
+
+// String returns the string representation of the raw symbol.
+func (body *RawBody) String() string {
+	// $80010000 Kind(156) raw 04 00 00 00
+	hex := make([]string, len(body.Data))
+	for i, b := range body.Data {
+		hex[i] = fmt.Sprintf("%02x", b)
+	}
+	if len(hex) == 0 {
+		return "raw"
+	}
+	return fmt.Sprintf("raw %s", strings.Join(hex, " "))
+}
+
+// BodySize returns the size of the symbol body in bytes.
+func (body *RawBody) BodySize() int {
+	return len(body.Data)
+}